@@ -15,15 +15,108 @@
 package piper
 
 import (
-	"fmt"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"strconv"
+	"text/template"
 )
 
+// The modes of printing banner.
+const (
+	// BannerModeConsole prints banner to the output of command, which is the default.
+	BannerModeConsole = "console"
+	// BannerModeLog prints banner with logger after logging initialized.
+	BannerModeLog = "log"
+	// BannerModeOff disables banner.
+	BannerModeOff = "off"
+)
+
+const (
+	bannerFile    = "banner.txt"
+	keyBannerMode = "piper.banner.mode"
+
+	defaultBannerText = "\n" +
+		"**********************************" + "\n" +
+		"*        Powered by piper        *" + "\n" +
+		"**********************************" + "\n"
+)
+
+// bannerColors are the ANSI color helpers which can be used in banner template, e.g.
+// {{ green "piper" }}.
+var bannerColors = template.FuncMap{
+	"black":   ansiColor(30),
+	"red":     ansiColor(31),
+	"green":   ansiColor(32),
+	"yellow":  ansiColor(33),
+	"blue":    ansiColor(34),
+	"magenta": ansiColor(35),
+	"cyan":    ansiColor(36),
+	"white":   ansiColor(37),
+	"bold":    ansiColor(1),
+	"faint":   ansiColor(2),
+}
+
+func init() {
+	Wire(&BannerProperty{})
+}
+
+// BannerProperty defines the property of piper.banner section in yaml config.
+type BannerProperty struct {
+	Mode string `piper:"mode" desc:"where to print banner, console, log or off, default is console" validate:"oneof=console log off"`
+}
+
+func (*BannerProperty) Prefix() string {
+	return "piper.banner"
+}
+
 // Banner defines type for banner which will be printed in console when starting.
 type Banner interface {
 	// Print prints banner content.
 	Print()
 }
 
+// EnvBanner can be implemented by Banner to print with the application environment,
+// e.g. render banner.txt in resources. It is used instead of Print if implemented, so
+// the banner can also be printed with logger in log mode.
+type EnvBanner interface {
+	// PrintTo prints banner content to writer.
+	PrintTo(env *AppEnv, w io.Writer) error
+}
+
+// BannerData is the data to render banner template.
+type BannerData struct {
+	AppName   string
+	Version   string
+	Profile   string
+	GoVersion string
+}
+
+// templateBanner renders the banner as go template with BannerData, and the color
+// helpers such as red, green and bold.
+type templateBanner struct {
+	text string
+}
+
+// NewTemplateBanner creates a banner with the go template text.
+func NewTemplateBanner(text string) Banner {
+	return &templateBanner{
+		text: text,
+	}
+}
+
+func (b *templateBanner) Print() {
+	_ = b.PrintTo(newAppEnv(), os.Stdout)
+}
+
+func (b *templateBanner) PrintTo(env *AppEnv, w io.Writer) error {
+	return renderBanner(b.text, env, w)
+}
+
+// defaultBanner renders the banner.txt in resources, and falls back to the builtin
+// one if not found.
 type defaultBanner struct {
 }
 
@@ -33,8 +126,39 @@ func NewDefaultBanner() *defaultBanner {
 }
 
 func (b *defaultBanner) Print() {
-	fmt.Printf("\n" +
-		"**********************************" + "\n" +
-		"*        Powered by piper        *" + "\n" +
-		"**********************************")
+	_ = b.PrintTo(newAppEnv(), os.Stdout)
+}
+
+func (b *defaultBanner) PrintTo(env *AppEnv, w io.Writer) error {
+	content, err := env.readResource(bannerFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		content, err = []byte(defaultBannerText), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return renderBanner(string(content), env, w)
+}
+
+// renderBanner renders the banner text as template to writer.
+func renderBanner(text string, env *AppEnv, w io.Writer) error {
+	tmpl, err := template.New(bannerFile).Funcs(bannerColors).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, &BannerData{
+		AppName:   env.GetString(piper + ".application.name"),
+		Version:   NewBuildInfo().Version,
+		Profile:   env.Profile(),
+		GoVersion: runtime.Version(),
+	})
+}
+
+// ansiColor creates a template func to wrap text with ANSI escape code.
+func ansiColor(code int) func(text string) string {
+	return func(text string) string {
+		return "\x1b[" + strconv.Itoa(code) + "m" + text + "\x1b[0m"
+	}
 }
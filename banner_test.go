@@ -0,0 +1,98 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type printOnlyBanner struct {
+	printed int
+}
+
+func (b *printOnlyBanner) Print() {
+	b.printed++
+}
+
+func TestBanner(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "banner test")
+}
+
+var _ = Describe("banner", func() {
+	var env *AppEnv
+
+	BeforeEach(func() {
+		env = newAppEnv()
+		env.configPaths = nil
+		env.viper().Set(keyProfile, "dev")
+		env.viper().Set(keyConfigLocation, []string{"/nonexistent"})
+		env.viper().Set(keyConfigAdditionalLocation, []string{"/nonexistent"})
+		env.resourceFs = newResourceFs(fstest.MapFS{})
+	})
+
+	It("render template", func() {
+		out := new(bytes.Buffer)
+		banner := NewTemplateBanner(`{{ .AppName }} {{ .Profile }} {{ .GoVersion }} {{ red "piper" }}`)
+		Expect(banner.(EnvBanner).PrintTo(env, out)).To(Succeed())
+		Expect(out.String()).To(Equal("piper-app dev " + runtime.Version() +
+			" \x1b[31mpiper\x1b[0m"))
+	})
+	It("read banner.txt in embedded resources", func() {
+		env.resourceFs = newResourceFs(fstest.MapFS{
+			"resources/banner.txt": {Data: []byte(`{{ bold .AppName }}`)},
+		})
+		out := new(bytes.Buffer)
+		Expect(NewDefaultBanner().PrintTo(env, out)).To(Succeed())
+		Expect(out.String()).To(Equal("\x1b[1mpiper-app\x1b[0m"))
+	})
+	It("fall back to default banner", func() {
+		out := new(bytes.Buffer)
+		Expect(NewDefaultBanner().PrintTo(env, out)).To(Succeed())
+		Expect(out.String()).To(Equal(defaultBannerText))
+	})
+	It("print in mode", func() {
+		cli := newCmdLine(env, nil, "test")
+		cli.Init(NewTemplateBanner("banner"))
+		out := new(bytes.Buffer)
+		cli.rootCmd.SetOut(out)
+
+		cli.printBanner()
+		Expect(out.String()).To(Equal("banner"))
+
+		out.Reset()
+		env.viper().Set(keyBannerMode, BannerModeOff)
+		cli.printBanner()
+		Expect(out.Len()).To(BeZero())
+	})
+	It("print banner without env", func() {
+		banner := &printOnlyBanner{}
+		cli := newCmdLine(env, nil, "test")
+		cli.Init(banner)
+		env.viper().Set(keyBannerMode, BannerModeLog)
+		cli.printBanner()
+		Expect(banner.printed).To(Equal(1))
+
+		env.viper().Set(keyBannerMode, BannerModeOff)
+		cli.printBanner()
+		Expect(banner.printed).To(Equal(1))
+	})
+})
@@ -0,0 +1,147 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const unknownBuildValue = "unknown"
+
+func init() {
+	Wire(NewBuildInfo)
+}
+
+// BuildInfo represents the build information of application. The values set with
+// ldflags take precedence, and the others are read from the information embedded by
+// go build, in which the build time is the time of VCS revision.
+type BuildInfo struct {
+	GoVersion    string        `json:"goVersion" yaml:"goVersion"`
+	Module       string        `json:"module,omitempty" yaml:"module,omitempty"`
+	Version      string        `json:"version" yaml:"version"`
+	GitCommit    string        `json:"gitCommit" yaml:"gitCommit"`
+	Dirty        bool          `json:"dirty" yaml:"dirty"`
+	BuildTime    string        `json:"buildTime" yaml:"buildTime"`
+	Dependencies []*Dependency `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+}
+
+// Dependency represents a module the application depends on.
+type Dependency struct {
+	Path    string `json:"path" yaml:"path"`
+	Version string `json:"version" yaml:"version"`
+	// Replace is the path of module which replaces this one, if any.
+	Replace string `json:"replace,omitempty" yaml:"replace,omitempty"`
+}
+
+// NewBuildInfo creates BuildInfo of current binary. This is wired in container, so it
+// can be injected as *BuildInfo.
+func NewBuildInfo() *BuildInfo {
+	info := &BuildInfo{
+		GoVersion: runtime.Version(),
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildTime: BuildTime,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Module = bi.Main.Path
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.GitCommit == "" {
+				info.GitCommit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = s.Value
+			}
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		}
+	}
+	for _, m := range bi.Deps {
+		dep := &Dependency{
+			Path:    m.Path,
+			Version: m.Version,
+		}
+		if m.Replace != nil {
+			dep.Replace = m.Replace.Path
+		}
+		info.Dependencies = append(info.Dependencies, dep)
+	}
+
+	return info
+}
+
+// Write writes the build information in the format, which can be text, json or yaml.
+func (b *BuildInfo) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		content, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(content))
+		return err
+	case "yaml":
+		return yaml.NewEncoder(w).Encode(b)
+	case "text":
+		return b.writeText(w)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func (b *BuildInfo) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range [][2]string{
+		{"Go version:", b.GoVersion},
+		{"Module:", b.Module},
+		{"Version:", b.Version},
+		{"Git commit:", b.GitCommit},
+		{"Dirty:", fmt.Sprint(b.Dirty)},
+		{"Build time:", b.BuildTime},
+	} {
+		if row[1] == "" {
+			row[1] = unknownBuildValue
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	if len(b.Dependencies) != 0 {
+		_, _ = fmt.Fprintln(tw, "Dependencies:")
+		for _, dep := range b.Dependencies {
+			version := dep.Version
+			if dep.Replace != "" {
+				version = fmt.Sprintf("%s => %s", version, dep.Replace)
+			}
+			_, _ = fmt.Fprintf(tw, "  %s\t%s\n", dep.Path, version)
+		}
+	}
+
+	return tw.Flush()
+}
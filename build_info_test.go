@@ -0,0 +1,78 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildInfo(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "build info test")
+}
+
+var _ = Describe("build info", func() {
+	info := &BuildInfo{
+		GoVersion: "go1.18",
+		Version:   "v1.0.0",
+		Dirty:     true,
+		Dependencies: []*Dependency{
+			{Path: "github.com/spf13/cobra", Version: "v1.4.0"},
+			{Path: "github.com/spf13/viper", Version: "v1.10.1", Replace: "../viper"},
+		},
+	}
+
+	It("ldflags take precedence", func() {
+		Version = "v2.0.0"
+		defer func() { Version = "" }()
+		Expect(NewBuildInfo().Version).To(Equal("v2.0.0"))
+	})
+	It("write text", func() {
+		out := new(bytes.Buffer)
+		Expect(info.Write(out, "text")).To(Succeed())
+		Expect(out.String()).To(Equal("" +
+			"Go version:  go1.18\n" +
+			"Module:      unknown\n" +
+			"Version:     v1.0.0\n" +
+			"Git commit:  unknown\n" +
+			"Dirty:       true\n" +
+			"Build time:  unknown\n" +
+			"Dependencies:\n" +
+			"  github.com/spf13/cobra  v1.4.0\n" +
+			"  github.com/spf13/viper  v1.10.1 => ../viper\n"))
+	})
+	It("write json and yaml", func() {
+		out := new(bytes.Buffer)
+		Expect(info.Write(out, "json")).To(Succeed())
+		decoded := &BuildInfo{}
+		Expect(json.Unmarshal(out.Bytes(), decoded)).To(Succeed())
+		Expect(decoded).To(Equal(info))
+
+		out.Reset()
+		Expect(info.Write(out, "yaml")).To(Succeed())
+		decoded = &BuildInfo{}
+		Expect(yaml.Unmarshal(out.Bytes(), decoded)).To(Succeed())
+		Expect(decoded).To(Equal(info))
+	})
+	It("unknown format", func() {
+		Expect(info.Write(new(bytes.Buffer), "xml")).To(MatchError("unknown format: xml"))
+	})
+})
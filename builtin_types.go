@@ -50,7 +50,7 @@ const (
 
 // ApplicationProperty defines the property of piper.application section in yaml config.
 type ApplicationProperty struct {
-	Name string `piper:"name"`
+	Name string `piper:"name" desc:"the name of application"`
 }
 
 func (*ApplicationProperty) Prefix() string {
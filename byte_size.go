@@ -0,0 +1,79 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize represents a size in bytes, e.g. 10MB in config file.
+type ByteSize int64
+
+const (
+	Byte     ByteSize = 1
+	KiloByte          = 1024 * Byte
+	MegaByte          = 1024 * KiloByte
+	GigaByte          = 1024 * MegaByte
+	TeraByte          = 1024 * GigaByte
+)
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   ByteSize
+}{
+	{"TB", TeraByte},
+	{"GB", GigaByte},
+	{"MB", MegaByte},
+	{"KB", KiloByte},
+	{"T", TeraByte},
+	{"G", GigaByte},
+	{"M", MegaByte},
+	{"K", KiloByte},
+	{"B", Byte},
+}
+
+// ParseByteSize parses a size string such as 512, 64KB or 10MB into ByteSize.
+// The units are case insensitive and based on 1024.
+func ParseByteSize(s string) (ByteSize, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	unit := Byte
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			unit = u.size
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid byte size: %s", s)
+	}
+
+	return ByteSize(value * float64(unit)), nil
+}
+
+// String returns the size with the largest unit which can represent it exactly.
+func (s ByteSize) String() string {
+	for _, u := range byteSizeUnits[:4] {
+		if s != 0 && s%u.size == 0 {
+			return fmt.Sprintf("%d%s", s/u.size, u.suffix)
+		}
+	}
+
+	return fmt.Sprintf("%dB", int64(s))
+}
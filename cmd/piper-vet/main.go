@@ -0,0 +1,28 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command piper-vet checks the misuse of piper wiring with go vet:
+//
+//	go install github.com/go-piper/piper/cmd/piper-vet@latest
+//	go vet -vettool=$(which piper-vet) ./...
+package main
+
+import (
+	"github.com/go-piper/piper/wirecheck"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(wirecheck.Analyzer)
+}
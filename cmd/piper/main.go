@@ -0,0 +1,98 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command piper is the tool for piper applications. Install it with:
+//
+//	go install github.com/go-piper/piper/cmd/piper@latest
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-piper/piper/internal/gen"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:           "piper",
+		Short:         "The tool for piper applications",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	rootCmd.AddCommand(newGenCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newGenCmd() *cobra.Command {
+	var output string
+	var profiles []string
+	var check bool
+
+	genCmd := &cobra.Command{
+		Use:   "gen [packages]",
+		Short: "Generate the code which constructs the graph without reflection",
+		Long: "Gen statically analyzes the Wire and WireWithOption calls in packages, " +
+			"and reports the resolution errors for each profile used in Active options. " +
+			"If no error found, it generates the dependency graph of each profile in " +
+			"main package, and the code calling the func providers in the packages " +
+			"where they were wired. The application uses the graph of active profile " +
+			"instead of resolving at runtime, and instantiates the providers in order " +
+			"without reflection. It falls back to resolving at runtime if the graph no " +
+			"longer matches the providers wired. Add the following in main package to " +
+			"regenerate with go generate:\n\n" +
+			"\t//go:generate piper gen ./...",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"./..."}
+			}
+			graph, err := gen.Load("", args...)
+			if err != nil {
+				return err
+			}
+			if len(profiles) == 0 {
+				profiles = append([]string{""}, graph.Profiles()...)
+			}
+
+			if check {
+				return graph.Resolve(profiles...)
+			}
+			files, err := graph.Generate(output, profiles...)
+			if err != nil {
+				return err
+			}
+			for path, src := range files {
+				if err := os.WriteFile(path, src, 0644); err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), path)
+			}
+
+			return nil
+		},
+	}
+	genCmd.Flags().StringVarP(&output, "output", "o", "piper_gen.go",
+		"the name of generated file in each package")
+	genCmd.Flags().StringSliceVarP(&profiles, "profile", "p", nil,
+		"the profiles to resolve for, defaults to no profile and the ones used in Active options")
+	genCmd.Flags().BoolVar(&check, "check", false,
+		"only report the resolution errors without generating code")
+
+	return genCmd
+}
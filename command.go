@@ -15,12 +15,15 @@
 package piper
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"reflect"
-	"runtime"
+	"strings"
 
+	"github.com/coolerfall/slago"
 	"github.com/spf13/cobra"
 )
 
@@ -29,6 +32,8 @@ type cmdLine struct {
 	env        *AppEnv
 	banner     Banner
 	engineFunc EngineFunc
+	binder     *propertyBinder
+	reloader   *configReloader
 }
 
 func newCmdLine(env *AppEnv, engineFunc EngineFunc, shortDesc string) *cmdLine {
@@ -43,16 +48,35 @@ func newCmdLine(env *AppEnv, engineFunc EngineFunc, shortDesc string) *cmdLine {
 		rootCmd:    rootCmd,
 		env:        env,
 		engineFunc: engineFunc,
+		binder:     newPropertyBinder(),
 	}
 }
 
 func (c *cmdLine) Init(banner Banner) {
 	c.banner = banner
 
+	// the flags to load configuration are shared with all the subcommands
+	flags := c.rootCmd.PersistentFlags()
+	flags.StringP(keyProfile, "p", "", "the profile to set")
+	flags.StringSlice("config-location", nil,
+		"the directories to search config files first")
+	flags.StringSlice("config-additional-location", nil,
+		"the additional directories to search config files at last")
+
 	// create start command
 	startCmd := c.newStartCmd()
-	startCmd.Flags().StringP(keyProfile, "p", "", "the profile to set")
-	err := c.env.vp.BindPFlag(keyProfile, startCmd.Flags().Lookup(keyProfile))
+	startCmd.Flags().Bool("startup-timing", false,
+		"print the slowest startup steps when application started")
+	for key, flag := range map[string]string{
+		keyProfile:                  keyProfile,
+		keyConfigLocation:           "config-location",
+		keyConfigAdditionalLocation: "config-additional-location",
+	} {
+		if err := c.env.viper().BindPFlag(key, flags.Lookup(flag)); err != nil {
+			Panicf("initialize command line error %v", err)
+		}
+	}
+	err := c.env.viper().BindPFlag(keyStartupTiming, startCmd.Flags().Lookup("startup-timing"))
 	if err != nil {
 		Panicf("initialize command line error %v", err)
 	}
@@ -61,7 +85,7 @@ func (c *cmdLine) Init(banner Banner) {
 	c.rootCmd.SetOut(stdOut)
 	c.rootCmd.SetErr(stdOut)
 
-	c.rootCmd.AddCommand(c.newVersionCmd(), startCmd)
+	c.rootCmd.AddCommand(c.newVersionCmd(), startCmd, c.newConfigCmd())
 }
 
 // Execute executes the root command which will start the aplication.
@@ -69,60 +93,320 @@ func (c *cmdLine) Execute() error {
 	return c.rootCmd.Execute()
 }
 
+// addCommand adds the subcommand whose run func is called with the command, args and
+// the dependencies resolved from container with the options, like a provider.
+func (c *cmdLine) addCommand(cmd *cobra.Command, run any, opts ...*WireOption) {
+	fnType := reflect.TypeOf(run)
+	if fnType == nil || fnType.Kind() != reflect.Func || fnType.NumIn() < 2 ||
+		fnType.In(0) != reflect.TypeOf(cmd) || fnType.In(1) != reflect.TypeOf([]string{}) ||
+		fnType.NumOut() != 1 || fnType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
+		Panicf("run of command %s should be func(*cobra.Command, []string, ...) error",
+			cmd.Name())
+	}
+
+	// subcommands do not start the application, so neither reloader nor lifecycle
+	// listeners are invoked
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := c.prepare(false); err != nil {
+			return err
+		}
+
+		// the command and args are bound, so the rest are resolved from container
+		deps := make([]reflect.Type, 0, fnType.NumIn()-2)
+		for i := 2; i < fnType.NumIn(); i++ {
+			deps = append(deps, fnType.In(i))
+		}
+		bound := []reflect.Value{reflect.ValueOf(cmd), reflect.ValueOf(args)}
+		fn := reflect.MakeFunc(reflect.FuncOf(deps, []reflect.Type{fnType.Out(0)}, false),
+			func(in []reflect.Value) []reflect.Value {
+				return reflect.ValueOf(run).Call(append(bound, in...))
+			})
+
+		tree := _depTree()
+		node, err := tree.resolveFunc("command "+cmd.CommandPath(), fn, opts...)
+		if err != nil {
+			return newAppStartError(err)
+		}
+		tree.instantiate(node)
+		if err, ok := node.provided.(error); ok {
+			return err
+		}
+
+		return nil
+	}
+	c.rootCmd.AddCommand(cmd)
+}
+
 func (c *cmdLine) newStartCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "start",
+		Use:   "start [args]",
 		Short: "Start to run application",
-		RunE: func(_ *cobra.Command, _ []string) error {
-			return c.run()
+		Long: "Start to run application, the args will be passed to ApplicationRunner, " +
+			"use -- before them if they start with -.",
+		RunE: func(_ *cobra.Command, args []string) error {
+			return c.run(args)
 		},
 	}
 }
 
 func (c *cmdLine) newVersionCmd() *cobra.Command {
-	return &cobra.Command{
+	versionCmd := &cobra.Command{
 		Use:   "version",
 		Short: fmt.Sprintf("Show the version of %v", c.env.cmdName()),
-		Run: func(_ *cobra.Command, _ []string) {
-			fmt.Printf(""+
-				"Go version:        %v\n"+
-				"Version:           %v\n"+
-				"Git commit:        %v\n"+
-				"Build time:             %v\n",
-				runtime.Version(), Version, GitCommit, BuildTime)
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, _ := cmd.Flags().GetString("output")
+			return NewBuildInfo().Write(cmd.OutOrStdout(), format)
 		},
 	}
+	versionCmd.Flags().StringP("output", "o", "text", "the output format, text, json or yaml")
+
+	return versionCmd
 }
 
-func (c *cmdLine) run() error {
-	c.banner.Print()
+func (c *cmdLine) newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Tools for configuration",
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "encrypt [value]",
+		Short: "Encrypt the value to be used as enc: secret in config file",
+		Long: fmt.Sprintf("Encrypt the value with AES-GCM key in %s or %s, the value "+
+			"will be read from stdin if not given.", EnvSecretKey, EnvSecretKeyFile),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var value string
+			if len(args) == 1 {
+				value = args[0]
+			} else {
+				content, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				value = strings.TrimRight(string(content), "\r\n")
+			}
 
-	if err := _depTree().resolveDependencies(); err != nil {
+			key, err := secretKey()
+			if err != nil {
+				return err
+			}
+			encrypted, err := encryptSecret(key, value)
+			if err != nil {
+				return err
+			}
+			cmd.Println(encryptedSecretPrefix + encrypted)
+
+			return nil
+		},
+	})
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Generate JSON Schema or reference docs for config file",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			tree := _depTree()
+			tree.profile = c.env.Profile()
+			if err := tree.resolveDependencies(); err != nil {
+				return err
+			}
+
+			var content []byte
+			var err error
+			switch format, _ := cmd.Flags().GetString("format"); format {
+			case "json":
+				content, err = GenerateConfigSchema(retrieveProperties(tree))
+			case "markdown":
+				content, err = GenerateConfigDocs(retrieveProperties(tree))
+			default:
+				err = fmt.Errorf("unknown format: %s", format)
+			}
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(content))
+
+			return nil
+		},
+	}
+	schemaCmd.Flags().StringP("format", "f", "json", "the output format, json or markdown")
+	configCmd.AddCommand(schemaCmd)
+
+	return configCmd
+}
+
+// run starts the application. The ApplicationRunner will run after started, and the
+// application stops after them if no engine, otherwise the engine starts to serve.
+func (c *cmdLine) run(args []string) error {
+	if err := c.prepare(true); err != nil {
 		return err
 	}
-	engine := c.engineFunc()
+	var engine AppEngine
+	if c.engineFunc != nil {
+		done := _timeline.track(phaseEngineCreate, "")
+		engine = c.engineFunc()
+		done()
+	}
 
-	c.captureExit(func() {
-		for _, l := range Retrieve[StopListener](reflect.TypeOf((*StopListener)(nil))) {
-			l.OnAppStop()
-			engine.Stop()
-		}
+	c.captureExit(func(sig os.Signal) {
+		publishAndLog(_depTree(), &ShutdownRequestedEvent{Signal: sig})
+		c.stop(engine)
 	})
 
 	for _, l := range Retrieve[StartListener](reflect.TypeOf((*StartListener)(nil))) {
+		done := _timeline.track(phaseStartListener, fmt.Sprintf("%T", l))
 		l.OnAppStart()
+		done()
+	}
+
+	if engine != nil {
+		publishAndLog(_depTree(), &EngineStartedEvent{Engine: engine.Name()})
+	}
+
+	// engine blocks when started, so report before starting it
+	steps := _timeline.finish()
+	for _, p := range Retrieve[*StartupProperty](reflect.TypeOf((*StartupProperty)(nil))) {
+		if err := reportStartup(c.rootCmd.OutOrStdout(), p, steps); err != nil {
+			slago.Logger().Warn().Err(err).Msg("write startup timeline error")
+		}
+	}
+
+	if err := runApplicationRunners(_depTree(), c.env, args); err != nil {
+		c.stop(engine)
+		return err
+	}
+	if engine == nil {
+		c.stop(nil)
+		return nil
 	}
 
 	return engine.Start(c.env)
 }
 
-func (c *cmdLine) captureExit(stop func()) {
+// stop stops the config reloader, notifies StopListener and stops engine if any.
+func (c *cmdLine) stop(engine AppEngine) {
+	if c.reloader != nil {
+		c.reloader.Stop()
+	}
+	for _, l := range Retrieve[StopListener](reflect.TypeOf((*StopListener)(nil))) {
+		l.OnAppStop()
+	}
+	if engine != nil {
+		engine.Stop()
+	}
+}
+
+// prepare resolves dependencies, loads configuration, invokes initializers and
+// binds all config properties before the engine starts. If the application is going
+// to start, the banner is printed after logging initialized, since the mode is in
+// configuration, and the config reloader is started if enabled.
+func (c *cmdLine) prepare(starting bool) error {
+	tree := _depTree()
+	tree.profile = c.env.Profile()
+	done := _timeline.track(phaseResolve, "")
+	err := tree.resolveDependencies()
+	done()
+	if err != nil {
+		return newAppStartError(err)
+	}
+	publishInstances(tree, &GraphResolvedEvent{})
+
+	if err := loadConfig(tree, c.env); err != nil {
+		return newAppStartError(newConfigError(err))
+	}
+	// validate properties before any initializer or provider depends on them
+	done = _timeline.track(phaseValidate, "")
+	_, err = c.binder.stage(c.env, retrieveProperties(tree))
+	done()
+	if err != nil {
+		return newAppStartError(newConfigError(err))
+	}
+	// initialize logging after application config loaded
+	if err := LoggingSystem().Initialize(c.env); err != nil {
+		return newAppStartError(newConfigError(err))
+	}
+	if starting {
+		c.printBanner()
+	}
+	slago.Logger().Info().Strs("files", c.env.ConfigFiles()).Msg("config files loaded")
+	publishInstances(tree, &ConfigLoadedEvent{Env: c.env})
+
+	for _, i := range Retrieve[Initializer](reflect.TypeOf((*Initializer)(nil))) {
+		done := _timeline.track(phaseInitializer, fmt.Sprintf("%T", i))
+		i.Initialize(c.env)
+		done()
+	}
+
+	done = _timeline.track(phaseBind, "")
+	err = c.binder.bind(c.env, retrieveProperties(tree))
+	done()
+	if err != nil {
+		return newAppStartError(newConfigError(err))
+	}
+
+	// instantiate all the providers in the order of generated graph if it was used
+	if err := tree.instantiateGraph(); err != nil {
+		return newAppStartError(err)
+	}
+
+	// instantiate all the providers concurrently after properties bound if enabled
+	for _, p := range Retrieve[*InstantiationProperty](
+		reflect.TypeOf((*InstantiationProperty)(nil))) {
+		if !p.Parallel {
+			continue
+		}
+		if err := tree.instantiateParallel(p.Workers); err != nil {
+			return newAppStartError(err)
+		}
+	}
+
+	for _, p := range Retrieve[*ReloadProperty](reflect.TypeOf((*ReloadProperty)(nil))) {
+		if !starting || !p.Enabled {
+			continue
+		}
+		c.reloader = newConfigReloader(tree, c.env, c.binder)
+		if err := c.reloader.Start(); err != nil {
+			return newAppStartError(err)
+		}
+	}
+
+	return nil
+}
+
+// printBanner prints banner in the configured mode, the failure of banner will not
+// stop the application. The banner which does not implement EnvBanner is always
+// printed by itself unless the mode is off.
+func (c *cmdLine) printBanner() {
+	mode := c.env.GetString(keyBannerMode)
+	if mode == BannerModeOff {
+		return
+	}
+	banner, ok := c.banner.(EnvBanner)
+	if !ok {
+		c.banner.Print()
+		return
+	}
+
+	var err error
+	if mode == BannerModeLog {
+		buffer := new(bytes.Buffer)
+		if err = banner.PrintTo(c.env, buffer); err == nil {
+			slago.Logger().Info().Msg("\n" + buffer.String())
+		}
+	} else {
+		err = banner.PrintTo(c.env, c.rootCmd.OutOrStdout())
+	}
+	if err != nil {
+		slago.Logger().Warn().Err(err).Msg("print banner error")
+	}
+}
+
+func (c *cmdLine) captureExit(stop func(sig os.Signal)) {
 	go func() {
 		sig := make(chan os.Signal, 1)
 		signal.Notify(sig, os.Interrupt, os.Kill)
 		// block until a signal is received
-		<-sig
-		stop()
+		stop(<-sig)
 		fmt.Println("process exited with code -1")
 		os.Exit(-1)
 	}()
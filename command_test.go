@@ -0,0 +1,79 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+)
+
+func TestCommand(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "command test")
+}
+
+var _ = Describe("command", func() {
+	It("add subcommand", func() {
+		cli := newCmdLine(newAppEnv(), nil, "test")
+		cli.Init(NewDefaultBanner())
+		cli.addCommand(&cobra.Command{Use: "migrate"},
+			func(_ *cobra.Command, _ []string, _ *ApplicationProperty) error { return nil })
+
+		cmd, _, err := cli.rootCmd.Find([]string{"migrate"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd.RunE).NotTo(BeNil())
+		Expect(cmd.InheritedFlags().Lookup(keyProfile)).NotTo(BeNil())
+	})
+	It("run subcommand with dependencies", func() {
+		dir, err := os.MkdirTemp("", "piper-command")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(os.WriteFile(filepath.Join(dir, "application.yml"),
+			[]byte("piper:\n  banner:\n    mode: off\n"), 0644)).To(Succeed())
+
+		cli := newCmdLine(newAppEnv(), nil, "test")
+		cli.Init(NewDefaultBanner())
+		var (
+			gotArgs []string
+			gotProp *ApplicationProperty
+		)
+		cli.addCommand(&cobra.Command{Use: "migrate"},
+			func(_ *cobra.Command, args []string, prop *ApplicationProperty) error {
+				gotArgs = args
+				gotProp = prop
+				return nil
+			})
+
+		cli.rootCmd.SetArgs([]string{"migrate", "--config-location", dir, "up", "2"})
+		Expect(cli.Execute()).To(Succeed())
+		Expect(gotArgs).To(Equal([]string{"up", "2"}))
+		Expect(gotProp).NotTo(BeNil())
+		Expect(cli.reloader).To(BeNil())
+	})
+	It("invalid run func", func() {
+		cli := newCmdLine(newAppEnv(), nil, "test")
+		Expect(func() {
+			cli.addCommand(&cobra.Command{Use: "seed"}, func(_ []string) error { return nil })
+		}).To(Panic())
+		Expect(func() {
+			cli.addCommand(&cobra.Command{Use: "seed"}, func(_ *cobra.Command, _ []string) {})
+		}).To(Panic())
+	})
+})
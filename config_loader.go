@@ -18,6 +18,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"reflect"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -35,6 +37,21 @@ func init() {
 	Wire(&applicationConfigLoader{})
 }
 
+// loadConfig loads configuration into env with all config loaders in tree in order.
+func loadConfig(tree *depTree, env *AppEnv) error {
+	defer _timeline.track(phaseConfig, "")()
+	for _, l := range retrieveFrom[ConfigLoader](tree, reflect.TypeOf((*ConfigLoader)(nil))) {
+		done := _timeline.track(phaseConfigLoader, fmt.Sprintf("%T", l))
+		err := l.Load(env)
+		done()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // applicationConfigLoader is a config loader to load configuration in application-xx.yml.
 type applicationConfigLoader struct {
 }
@@ -43,6 +60,8 @@ func (s *applicationConfigLoader) Order() int {
 	return math.MinInt32 + 1
 }
 
+// Load loads application-{profile}.yml if a profile is active, or application.yml if
+// the profile config does not exist or no profile is active.
 func (s *applicationConfigLoader) Load(env *AppEnv) error {
 	configName := env.ConfigName()
 	profile := env.Profile()
@@ -50,18 +69,14 @@ func (s *applicationConfigLoader) Load(env *AppEnv) error {
 		configName += "-" + profile
 	}
 
-	if err := s.readConfig(env, configName, false); err != nil {
-		return err
-	}
-
-	// initialize logging after application config loaded
-	return LoggingSystem().Initialize(env)
+	return s.readConfig(env, configName, false)
 }
 
 func (s *applicationConfigLoader) readConfig(env *AppEnv,
-	configName string, rollback bool) (err error) {
-	if err = env.mergeWith(configName); err == nil {
-		return nil
+	configName string, rollback bool) error {
+	err := env.mergeWith(configName)
+	if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+		return err
 	}
 
 	// if no default config file found, just return error
@@ -69,11 +84,7 @@ func (s *applicationConfigLoader) readConfig(env *AppEnv,
 		return s.readError(env)
 	}
 
-	if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-		return s.readError(env)
-	} else {
-		return s.readConfig(env, env.ConfigName(), true)
-	}
+	return s.readConfig(env, env.ConfigName(), true)
 }
 
 func (s *applicationConfigLoader) readError(env *AppEnv) error {
@@ -82,7 +93,8 @@ func (s *applicationConfigLoader) readError(env *AppEnv) error {
 		profileConfigErrMsg = fmt.Sprintf(" or %v-%v.yml", env.ConfigName(), env.Profile())
 	}
 	return errors.New(
-		fmt.Sprintf("no %v.yml%v config file found in resources, "+
+		fmt.Sprintf("no %v.yml%v config file found in:\n\t%s\n"+
 			"at least one config file should be presented",
-			env.ConfigName(), profileConfigErrMsg))
+			env.ConfigName(), profileConfigErrMsg,
+			strings.Join(env.ConfigSearchPaths(), "\n\t")))
 }
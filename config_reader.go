@@ -0,0 +1,223 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	keyConfigImport          = "piper.config.import"
+	keyConfigActivateProfile = "piper.config.activate.on-profile"
+
+	optionalImportPrefix = "optional:"
+	fileImportPrefix     = "file:"
+)
+
+// configReader reads yaml config file with multiple documents and imports. The
+// documents will be returned in the order of merge precedence, the latter one has
+// higher precedence than the former one:
+//
+//  1. documents in the same file are merged in the order they appear
+//  2. documents gated by piper.config.activate.on-profile are skipped unless the
+//     current profile matches one of the profiles, e.g. `dev` or `dev,test`
+//  3. the files in piper.config.import of one document are merged right after the
+//     document in the order they are listed, so the imported files override the
+//     document which imports them
+//
+// Imports are relative to the directory of the importing file unless prefixed with
+// `file:`. The import with `optional:` prefix will be ignored if not found, e.g.
+//
+//	piper:
+//	  config:
+//	    import: [optional:db.yml, logging.yml, file:/etc/myapp/overrides.yml]
+type configReader struct {
+	profile string
+	fs      afero.Fs
+	files   []string
+}
+
+func newConfigReader(profile string, fs afero.Fs) *configReader {
+	return &configReader{
+		profile: profile,
+		fs:      fs,
+	}
+}
+
+// read reads all active documents in the given file and its imports.
+func (r *configReader) read(filename string) ([]map[string]any, error) {
+	return r.readFile(filename, make([]string, 0))
+}
+
+// readFiles returns all the files which have been read.
+func (r *configReader) readFiles() []string {
+	return r.files
+}
+
+func (r *configReader) readFile(filename string, chain []string) ([]map[string]any, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range chain {
+		if f == filename {
+			return nil, newImportCycleError(append(chain[i:], filename))
+		}
+	}
+	chain = append(chain, filename)
+
+	content, err := afero.ReadFile(r.fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	r.files = append(r.files, filename)
+
+	docs, err := decodeYamlDocuments(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s error: %v", filename, err)
+	}
+
+	result := make([]map[string]any, 0)
+	for _, doc := range docs {
+		if !r.activated(doc) {
+			continue
+		}
+		result = append(result, doc)
+
+		imported, err := r.readImports(filepath.Dir(filename), doc, chain)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, imported...)
+	}
+
+	return result, nil
+}
+
+func (r *configReader) readImports(dir string, doc map[string]any,
+	chain []string) ([]map[string]any, error) {
+	result := make([]map[string]any, 0)
+
+	for _, imp := range toStringSlice(lookupConfigKey(doc, keyConfigImport)) {
+		path := ExpandEnv(strings.TrimSpace(imp))
+		if len(path) == 0 {
+			continue
+		}
+		optional := strings.HasPrefix(path, optionalImportPrefix)
+		path = strings.TrimPrefix(path, optionalImportPrefix)
+
+		if strings.HasPrefix(path, fileImportPrefix) {
+			path = strings.TrimPrefix(path, fileImportPrefix)
+		} else if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		docs, err := r.readFile(path, chain)
+		if err != nil {
+			if optional && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("import config %s error: %w", imp, err)
+		}
+		result = append(result, docs...)
+	}
+
+	return result, nil
+}
+
+// activated checks if the document is activated by current profile.
+func (r *configReader) activated(doc map[string]any) bool {
+	value := lookupConfigKey(doc, keyConfigActivateProfile)
+	if value == nil {
+		return true
+	}
+
+	for _, p := range toStringSlice(value) {
+		if strings.TrimSpace(p) == r.profile {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeYamlDocuments decodes all the documents separated by `---` in yaml content.
+func decodeYamlDocuments(content []byte) ([]map[string]any, error) {
+	docs := make([]map[string]any, 0)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	for {
+		doc := make(map[string]any)
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// lookupConfigKey finds the value with dot separated key in nested config map.
+// The key is case insensitive.
+func lookupConfigKey(cfg map[string]any, key string) any {
+	var current any = cfg
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		current = nil
+		for k, v := range m {
+			if strings.EqualFold(k, part) {
+				current = v
+				break
+			}
+		}
+		if current == nil {
+			return nil
+		}
+	}
+
+	return current
+}
+
+// toStringSlice converts string separated by comma or list into string slice.
+func toStringSlice(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Split(v, ",")
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, fmt.Sprint(item))
+		}
+		return result
+	default:
+		return nil
+	}
+}
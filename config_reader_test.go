@@ -0,0 +1,125 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+func writeConfigFiles(files map[string]string) string {
+	dir, err := os.MkdirTemp("", "piper-config")
+	Expect(err).NotTo(HaveOccurred())
+	for name, content := range files {
+		Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)).To(Succeed())
+	}
+
+	return dir
+}
+
+func TestConfigReader(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "config reader test")
+}
+
+var _ = Describe("config reader", func() {
+	It("imports and profile documents", func() {
+		dir := writeConfigFiles(map[string]string{
+			"application.yml": "" +
+				"piper:\n" +
+				"  config:\n" +
+				"    import: [optional:missing.yml, db.yml]\n" +
+				"db:\n" +
+				"  url: default\n" +
+				"  pool: 1\n" +
+				"---\n" +
+				"piper:\n" +
+				"  config:\n" +
+				"    activate:\n" +
+				"      on-profile: dev,test\n" +
+				"db:\n" +
+				"  pool: 5\n" +
+				"---\n" +
+				"piper:\n" +
+				"  config:\n" +
+				"    activate:\n" +
+				"      on-profile: prod\n" +
+				"db:\n" +
+				"  pool: 100\n",
+			"db.yml": "db:\n  url: imported\n",
+		})
+		defer os.RemoveAll(dir)
+
+		env := newAppEnv()
+		env.configPaths = []string{dir}
+		env.vp = env.newViper()
+		env.vp.Set(keyProfile, "test")
+		Expect(env.mergeWith("application")).To(Succeed())
+
+		Expect(env.viper().GetString("db.url")).To(Equal("imported"))
+		Expect(env.viper().GetInt("db.pool")).To(Equal(5))
+		Expect(env.ConfigFiles()).To(Equal([]string{
+			filepath.Join(dir, "application.yml"),
+			filepath.Join(dir, "db.yml"),
+		}))
+	})
+	It("import cycle", func() {
+		dir := writeConfigFiles(map[string]string{
+			"a.yml": "piper:\n  config:\n    import: b.yml\n",
+			"b.yml": "piper:\n  config:\n    import: a.yml\n",
+		})
+		defer os.RemoveAll(dir)
+
+		_, err := newConfigReader("", afero.NewOsFs()).read(filepath.Join(dir, "a.yml"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("cycle config imports found"))
+	})
+	It("required import not found", func() {
+		dir := writeConfigFiles(map[string]string{
+			"a.yml": "piper:\n  config:\n    import: missing.yml\n",
+		})
+		defer os.RemoveAll(dir)
+
+		_, err := newConfigReader("", afero.NewOsFs()).read(filepath.Join(dir, "a.yml"))
+		Expect(err).To(HaveOccurred())
+	})
+	It("load with import errors", func() {
+		dir := writeConfigFiles(map[string]string{
+			"application.yml":     "piper:\n  config:\n    import: broken.yml\n",
+			"broken.yml":          "db: [\n",
+			"application-dev.yml": "piper:\n  config:\n    import: cycle.yml\n",
+			"cycle.yml":           "piper:\n  config:\n    import: application-dev.yml\n",
+		})
+		defer os.RemoveAll(dir)
+
+		env := newAppEnv()
+		env.viper().Set(keyConfigLocation, []string{dir})
+		err := (&applicationConfigLoader{}).Load(env)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("import config broken.yml error"))
+
+		env = newAppEnv()
+		env.viper().Set(keyConfigLocation, []string{dir})
+		env.viper().Set(keyProfile, "dev")
+		err = (&applicationConfigLoader{}).Load(env)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("cycle config imports found"))
+	})
+})
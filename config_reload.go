@@ -0,0 +1,288 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coolerfall/slago"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is the delay to wait for more file events before reloading, editors
+// usually write files more than once when saving.
+const reloadDelay = 200 * time.Millisecond
+
+func init() {
+	Wire(&ReloadProperty{})
+}
+
+// ReloadProperty defines the property of piper.config.reload section in yaml config.
+// The properties are re-bound in place when reloaded, so they should be read with
+// Snapshot by the providers when reload is enabled.
+type ReloadProperty struct {
+	Enabled bool `piper:"enabled" desc:"reload configuration when config files changed"`
+}
+
+func (*ReloadProperty) Prefix() string {
+	return "piper.config.reload"
+}
+
+// ConfigChangeEvent represents the event after configuration has been reloaded. It is
+// also published to Listener[*ConfigChangeEvent].
+type ConfigChangeEvent struct {
+	// Keys contains all changed keys in flatten form, e.g. piper.logging.level.
+	Keys []string
+}
+
+// ConfigChangeListener defines the listener interface which will be notified after
+// configuration has been reloaded and all ConfigProperty have been re-bound. Read the
+// properties with Snapshot, since they may be re-bound again concurrently.
+type ConfigChangeListener interface {
+	// OnConfigChange will be invoked with changed keys after configuration reloaded.
+	OnConfigChange(event *ConfigChangeEvent)
+}
+
+// ConfigWatcher can be implemented by ConfigLoader to watch the changes of config
+// which are not in local files, such as remote config. The watchers will be started
+// when reload is enabled.
+type ConfigWatcher interface {
+	// Watch starts to watch changes and invokes onChange when config changed. It
+	// returns a func to stop watching.
+	Watch(env *AppEnv, onChange func()) func()
+}
+
+// configReloader watches the config files used by AppEnv and the config loaders which
+// implement ConfigWatcher, and reloads configuration with all config loaders when any
+// of them has changed. The reload will be rolled back
+// if any error occurs, so the configuration and properties will never be half applied.
+type configReloader struct {
+	tree    *depTree
+	env     *AppEnv
+	binder  *propertyBinder
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	timer   *time.Timer
+	stops   []func()
+}
+
+func newConfigReloader(tree *depTree, env *AppEnv, binder *propertyBinder) *configReloader {
+	return &configReloader{
+		tree:   tree,
+		env:    env,
+		binder: binder,
+	}
+}
+
+// Start starts to watch all config files used currently.
+func (r *configReloader) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// watch directories instead of files, so the files replaced by editors still work
+	dirs := make(map[string]bool)
+	for _, f := range r.env.ConfigFiles() {
+		dir := filepath.Dir(f)
+		// the embedded resources cannot be changed
+		if _, err = os.Stat(dir); dirs[dir] || err != nil {
+			continue
+		}
+		if err = watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return err
+		}
+		dirs[dir] = true
+	}
+
+	r.mu.Lock()
+	r.watcher = watcher
+	r.mu.Unlock()
+
+	go r.watch(watcher)
+
+	for _, l := range retrieveFrom[ConfigLoader](r.tree,
+		reflect.TypeOf((*ConfigLoader)(nil))) {
+		if w, ok := l.(ConfigWatcher); ok {
+			stop := w.Watch(r.env, r.scheduleReload)
+			r.mu.Lock()
+			r.stops = append(r.stops, stop)
+			r.mu.Unlock()
+		}
+	}
+
+	return nil
+}
+
+// Stop stops watching config files.
+func (r *configReloader) Stop() {
+	r.mu.Lock()
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	if r.watcher != nil {
+		_ = r.watcher.Close()
+		r.watcher = nil
+	}
+	stops := r.stops
+	r.stops = nil
+	r.mu.Unlock()
+
+	// the watchers may be scheduling reload, so stop them without lock held
+	for _, stop := range stops {
+		stop()
+	}
+}
+
+func (r *configReloader) watch(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 ||
+				!r.isConfigFile(event.Name) {
+				continue
+			}
+			r.scheduleReload()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slago.Logger().Warn().Err(err).Msg("watch config files error")
+		}
+	}
+}
+
+func (r *configReloader) isConfigFile(name string) bool {
+	name = filepath.Clean(name)
+	for _, f := range r.env.ConfigFiles() {
+		if filepath.Clean(f) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *configReloader) scheduleReload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(reloadDelay, func() {
+		if err := r.Reload(); err != nil {
+			slago.Logger().Error().Err(err).Msg("reload configuration fail, rolled back")
+		}
+	})
+}
+
+// Reload loads configuration with all config loaders into a fresh environment and
+// re-binds all properties. Nothing will be changed if any error occurs.
+func (r *configReloader) Reload() error {
+	candidate := r.env.fork()
+	if err := loadConfig(r.tree, candidate); err != nil {
+		return err
+	}
+
+	staged, err := r.binder.stage(candidate, retrieveProperties(r.tree))
+	if err != nil {
+		return err
+	}
+
+	oldSettings := r.env.viper().AllSettings()
+	old := r.env.replaceWith(candidate)
+	previous := r.binder.commit(staged)
+
+	if err = LoggingSystem().Initialize(r.env); err != nil {
+		r.env.replaceWith(old)
+		r.binder.commit(previous)
+		if rbErr := LoggingSystem().Initialize(r.env); rbErr != nil {
+			return fmt.Errorf("%v, and roll back logging error: %v", err, rbErr)
+		}
+		return err
+	}
+
+	keys := changedKeys(oldSettings, r.env.viper().AllSettings())
+	if len(keys) == 0 {
+		return nil
+	}
+
+	event := &ConfigChangeEvent{
+		Keys: keys,
+	}
+	for _, l := range retrieveFrom[ConfigChangeListener](r.tree,
+		reflect.TypeOf((*ConfigChangeListener)(nil))) {
+		l.OnConfigChange(event)
+	}
+	publishAndLog(r.tree, event)
+
+	return nil
+}
+
+// retrieveProperties retrieves all config properties wired in tree.
+func retrieveProperties(tree *depTree) []ConfigProperty {
+	return retrieveFrom[ConfigProperty](tree, reflect.TypeOf((*ConfigProperty)(nil)))
+}
+
+// changedKeys compares two nested settings and returns all the changed keys in order.
+func changedKeys(oldCfg, newCfg map[string]any) []string {
+	oldFlat := make(map[string]any)
+	newFlat := make(map[string]any)
+	flattenSettings("", oldCfg, oldFlat)
+	flattenSettings("", newCfg, newFlat)
+
+	keys := make([]string, 0)
+	for k, v := range newFlat {
+		if ov, ok := oldFlat[k]; !ok || !reflect.DeepEqual(ov, v) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range oldFlat {
+		if _, ok := newFlat[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func flattenSettings(prefix string, cfg map[string]any, flat map[string]any) {
+	for k, v := range cfg {
+		key := strings.ToLower(k)
+		if len(prefix) != 0 {
+			key = prefix + "." + key
+		}
+
+		if child, ok := v.(map[string]any); ok {
+			flattenSettings(key, child, flat)
+		} else {
+			flat[key] = v
+		}
+	}
+}
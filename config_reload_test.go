@@ -0,0 +1,215 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type reloadTestProperty struct {
+	Host string `piper:"host"`
+	Port int    `piper:"port"`
+}
+
+func (*reloadTestProperty) Prefix() string {
+	return "reload-test"
+}
+
+type reloadTestDb struct {
+	Url string `piper:"url"`
+}
+
+type reloadNestedProperty struct {
+	Port   int               `piper:"port" validate:"min=1"`
+	Db     *reloadTestDb     `piper:"db"`
+	Hosts  []string          `piper:"hosts"`
+	Labels map[string]string `piper:"labels"`
+}
+
+func (*reloadNestedProperty) Prefix() string {
+	return "reload-nested"
+}
+
+type reloadTestListener struct {
+	changed   [][]string
+	published [][]string
+}
+
+func (l *reloadTestListener) OnConfigChange(event *ConfigChangeEvent) {
+	l.changed = append(l.changed, event.Keys)
+}
+
+func (l *reloadTestListener) OnEvent(event *ConfigChangeEvent) error {
+	l.published = append(l.published, event.Keys)
+	return nil
+}
+
+func TestConfigReload(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "config reload test")
+}
+
+var _ = Describe("config reload", func() {
+	It("changed keys", func() {
+		keys := changedKeys(map[string]any{
+			"piper": map[string]any{
+				"logging": map[string]any{"level": "info"},
+				"removed": "value",
+			},
+			"same": 1,
+		}, map[string]any{
+			"piper": map[string]any{
+				"logging": map[string]any{"level": "debug"},
+			},
+			"same":  1,
+			"added": true,
+		})
+		Expect(keys).To(Equal([]string{"added", "piper.logging.level", "piper.removed"}))
+	})
+	It("rebind from defaults and roll back", func() {
+		prop := &reloadTestProperty{Host: "localhost", Port: 80}
+		binder := newPropertyBinder()
+
+		env := newAppEnv()
+		Expect(env.MergeConfigMap(map[string]any{
+			"reload-test": map[string]any{"port": 8080},
+		})).To(Succeed())
+		Expect(binder.bind(env, []ConfigProperty{prop})).To(Succeed())
+		Expect(*prop).To(Equal(reloadTestProperty{Host: "localhost", Port: 8080}))
+
+		candidate := env.fork()
+		Expect(candidate.MergeConfigMap(map[string]any{
+			"reload-test": map[string]any{"host": "example.com"},
+		})).To(Succeed())
+		staged, err := binder.stage(candidate, []ConfigProperty{prop})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prop.Host).To(Equal("localhost"))
+
+		previous := binder.commit(staged)
+		Expect(*prop).To(Equal(reloadTestProperty{Host: "example.com", Port: 80}))
+
+		binder.commit(previous)
+		Expect(*prop).To(Equal(reloadTestProperty{Host: "localhost", Port: 8080}))
+	})
+	It("read snapshot while re-binding", func() {
+		prop := &reloadTestProperty{Host: "localhost", Port: 80}
+		binder := newPropertyBinder()
+		staged := []*stagedProperty{{property: prop,
+			value: reflect.ValueOf(reloadTestProperty{Host: "example.com", Port: 8080})}}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				staged = binder.commit(staged)
+			}
+		}()
+		for i := 0; i < 100; i++ {
+			Expect(Snapshot(prop)).To(Or(
+				Equal(reloadTestProperty{Host: "localhost", Port: 80}),
+				Equal(reloadTestProperty{Host: "example.com", Port: 8080})))
+		}
+		<-done
+	})
+	It("reload nested property and roll back", func() {
+		dir := writeConfigFiles(map[string]string{
+			"application.yml": "" +
+				"reload-nested:\n" +
+				"  port: 8080\n" +
+				"  db:\n" +
+				"    url: first\n" +
+				"  hosts: [a, b]\n" +
+				"  labels:\n" +
+				"    env: dev\n",
+		})
+		defer os.RemoveAll(dir)
+		writeConfig := func(content string) {
+			Expect(os.WriteFile(filepath.Join(dir, "application.yml"),
+				[]byte(content), 0600)).To(Succeed())
+		}
+
+		prop := &reloadNestedProperty{
+			Port:   80,
+			Db:     &reloadTestDb{Url: "default"},
+			Labels: map[string]string{"team": "core"},
+		}
+		listener := &reloadTestListener{}
+		tree := newDepTree()
+		tree.wire(&applicationConfigLoader{}, prop, listener)
+		Expect(tree.resolveDependencies()).To(Succeed())
+		retrieveFrom[Listener[*ConfigChangeEvent]](tree,
+			reflect.TypeOf((*Listener[*ConfigChangeEvent])(nil)))
+
+		env := newAppEnv()
+		// these are bound from command line flags, and forked into reloaded config
+		env.viper().Set(keyProfile, "")
+		env.viper().Set(keyConfigLocation, []string{dir})
+		env.viper().Set(keyConfigAdditionalLocation, []string{})
+		binder := newPropertyBinder()
+		Expect(loadConfig(tree, env)).To(Succeed())
+		Expect(binder.bind(env, retrieveProperties(tree))).To(Succeed())
+		Expect(prop.Db.Url).To(Equal("first"))
+		Expect(prop.Labels).To(Equal(map[string]string{"team": "core", "env": "dev"}))
+
+		reloader := newConfigReloader(tree, env, binder)
+		first := prop.Db
+		writeConfig("" +
+			"reload-nested:\n" +
+			"  port: 9090\n" +
+			"  db:\n" +
+			"    url: second\n" +
+			"  hosts: [c]\n")
+		Expect(reloader.Reload()).To(Succeed())
+		// the defaults and the previous value are not changed by staging
+		Expect(first.Url).To(Equal("first"))
+		Expect(*prop).To(Equal(reloadNestedProperty{
+			Port:   9090,
+			Db:     &reloadTestDb{Url: "second"},
+			Hosts:  []string{"c"},
+			Labels: map[string]string{"team": "core"},
+		}))
+		keys := []string{"reload-nested.db.url", "reload-nested.hosts",
+			"reload-nested.labels.env", "reload-nested.port"}
+		Expect(listener.changed).To(Equal([][]string{keys}))
+		Expect(listener.published).To(Equal([][]string{keys}))
+
+		second := prop.Db
+		writeConfig("" +
+			"reload-nested:\n" +
+			"  port: 0\n" +
+			"  db:\n" +
+			"    url: third\n" +
+			"  labels:\n" +
+			"    env: prod\n")
+		err := reloader.Reload()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("reload-nested.port"))
+		Expect(prop.Db).To(BeIdenticalTo(second))
+		Expect(*prop).To(Equal(reloadNestedProperty{
+			Port:   9090,
+			Db:     &reloadTestDb{Url: "second"},
+			Hosts:  []string{"c"},
+			Labels: map[string]string{"team": "core"},
+		}))
+		Expect(env.viper().GetInt("reload-nested.port")).To(Equal(9090))
+		Expect(listener.changed).To(HaveLen(1))
+	})
+})
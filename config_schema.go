@@ -0,0 +1,307 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	descTag     = "desc"
+	schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+)
+
+var (
+	urlType      = reflect.TypeOf(url.URL{})
+	ipType       = reflect.TypeOf(net.IP{})
+	locationType = reflect.TypeOf(time.Location{})
+)
+
+// GenerateConfigSchema generates JSON Schema of config file for the given properties.
+// The type, default value and validate rules of each field are described in schema,
+// and the description comes from `desc` tag. For example:
+//
+//  type DbProperty struct {
+//      Url  string `piper:"url" validate:"required" desc:"the url of database"`
+//      Pool int    `piper:"pool" desc:"the max size of connection pool"`
+//  }
+//
+// The wired values of properties are treated as default values.
+func GenerateConfigSchema(props []ConfigProperty) ([]byte, error) {
+	root := newObjectSchema()
+	root["$schema"] = schemaDraft
+
+	for _, p := range props {
+		pValue := reflect.ValueOf(p)
+		if pValue.Kind() != reflect.Ptr || pValue.Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("config property should be pointer of struct: %T", p)
+		}
+
+		parent := root
+		parts := strings.Split(p.Prefix(), ".")
+		for _, part := range parts[:len(parts)-1] {
+			parent = childObjectSchema(parent, part)
+		}
+
+		schema := structSchema(pValue.Elem().Type(), pValue.Elem())
+		name := parts[len(parts)-1]
+		if existing, ok := parent["properties"].(map[string]any)[name].(map[string]any); ok {
+			// merge with the nested properties of other config property
+			for k, v := range existing["properties"].(map[string]any) {
+				schema["properties"].(map[string]any)[k] = v
+			}
+		}
+		parent["properties"].(map[string]any)[name] = schema
+	}
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+// GenerateConfigDocs generates markdown reference docs for the given properties.
+// Each key in config file will be listed with type, default value and description.
+func GenerateConfigDocs(props []ConfigProperty) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("| Key | Type | Default | Description |\n")
+	buffer.WriteString("| --- | --- | --- | --- |\n")
+
+	sorted := append([]ConfigProperty(nil), props...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Prefix() < sorted[j].Prefix()
+	})
+
+	for _, p := range sorted {
+		pValue := reflect.ValueOf(p)
+		if pValue.Kind() != reflect.Ptr || pValue.Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("config property should be pointer of struct: %T", p)
+		}
+		writeDocRows(buffer, p.Prefix(), structSchema(pValue.Elem().Type(), pValue.Elem()))
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func writeDocRows(buffer *bytes.Buffer, key string, schema map[string]any) {
+	props, ok := schema["properties"].(map[string]any)
+	if ok && len(props) != 0 {
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			writeDocRows(buffer, key+"."+name, props[name].(map[string]any))
+		}
+		return
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		if _, ok = items["properties"]; ok {
+			writeDocRows(buffer, key+"[]", items)
+			return
+		}
+	}
+
+	var def string
+	if v, ok := schema["default"]; ok {
+		def = fmt.Sprintf("`%v`", v)
+	}
+	desc, _ := schema["description"].(string)
+	buffer.WriteString(fmt.Sprintf("| `%s` | %v | %s | %s |\n", key, schema["type"], def, desc))
+}
+
+func newObjectSchema() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": make(map[string]any),
+	}
+}
+
+func childObjectSchema(parent map[string]any, name string) map[string]any {
+	props := parent["properties"].(map[string]any)
+	if child, ok := props[name].(map[string]any); ok {
+		return child
+	}
+
+	child := newObjectSchema()
+	props[name] = child
+	return child
+}
+
+// structSchema creates object schema for struct type, the value is used as default.
+func structSchema(structType reflect.Type, value reflect.Value) map[string]any {
+	schema := newObjectSchema()
+	props := schema["properties"].(map[string]any)
+	required := make([]string, 0)
+
+	for i := 0; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		name := strings.Split(structField.Tag.Get(piper), ",")[0]
+		if len(name) == 0 {
+			name = structField.Name
+		}
+
+		var fieldValue reflect.Value
+		if value.IsValid() {
+			fieldValue = value.Field(i)
+		}
+		fieldSchema := typeSchema(structField.Type, fieldValue)
+		if desc, ok := structField.Tag.Lookup(descTag); ok {
+			fieldSchema["description"] = desc
+		}
+		if rules, ok := structField.Tag.Lookup(validateTag); ok {
+			if applyRuleSchema(fieldSchema, structField.Type, rules) {
+				required = append(required, name)
+			}
+		}
+		props[name] = fieldSchema
+	}
+
+	if len(required) != 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func typeSchema(fieldType reflect.Type, value reflect.Value) map[string]any {
+	if fieldType.Kind() == reflect.Ptr {
+		if value.IsValid() && !value.IsNil() {
+			value = value.Elem()
+		} else {
+			value = reflect.Value{}
+		}
+		fieldType = fieldType.Elem()
+	}
+
+	schema := make(map[string]any)
+	switch {
+	case fieldType == durationType:
+		schema["type"] = "string"
+		schema["format"] = "duration"
+		if value.IsValid() && !value.IsZero() {
+			schema["default"] = value.Interface().(time.Duration).String()
+		}
+		return schema
+	case fieldType == byteSizeType:
+		schema["type"] = []string{"string", "integer"}
+		if value.IsValid() && !value.IsZero() {
+			schema["default"] = value.Interface().(ByteSize).String()
+		}
+		return schema
+	case fieldType == urlType:
+		schema["type"] = "string"
+		schema["format"] = "uri"
+		return schema
+	case fieldType == ipType:
+		schema["type"] = "string"
+		schema["format"] = "ip"
+		return schema
+	case fieldType == locationType,
+		fieldType.Kind() != reflect.String &&
+			reflect.PtrTo(fieldType).Implements(textUnmarshalerType):
+		schema["type"] = "string"
+		return schema
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Struct:
+		return structSchema(fieldType, value)
+	case reflect.Slice, reflect.Array:
+		schema["type"] = "array"
+		schema["items"] = typeSchema(fieldType.Elem(), reflect.Value{})
+	case reflect.Map:
+		schema["type"] = "object"
+		schema["additionalProperties"] = typeSchema(fieldType.Elem(), reflect.Value{})
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+	}
+
+	if value.IsValid() && !value.IsZero() && fieldType.Kind() != reflect.Struct {
+		schema["default"] = value.Interface()
+	}
+
+	return schema
+}
+
+// applyRuleSchema adds validate rules into schema, and returns true if the field is
+// required.
+func applyRuleSchema(schema map[string]any, fieldType reflect.Type, rules string) bool {
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+
+	var required bool
+	for _, r := range parseRules(rules) {
+		switch r.name {
+		case "required":
+			required = true
+		case "oneof":
+			schema["enum"] = strings.Fields(r.arg)
+		case "regexp":
+			schema["pattern"] = r.arg
+		case "min", "max":
+			applyRangeSchema(schema, fieldType, r.name, r.arg)
+		}
+	}
+
+	return required
+}
+
+func applyRangeSchema(schema map[string]any, fieldType reflect.Type, name, arg string) {
+	bound, err := strconv.ParseFloat(arg, 64)
+	if err != nil || fieldType == durationType || fieldType == byteSizeType {
+		// durations and byte sizes are described as string
+		return
+	}
+
+	var keyword string
+	switch fieldType.Kind() {
+	case reflect.String:
+		keyword = "Length"
+	case reflect.Slice, reflect.Array:
+		keyword = "Items"
+	case reflect.Map:
+		keyword = "Properties"
+	default:
+		if name == "min" {
+			schema["minimum"] = bound
+		} else {
+			schema["maximum"] = bound
+		}
+		return
+	}
+
+	schema[name+keyword] = int(bound)
+}
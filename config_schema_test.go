@@ -0,0 +1,87 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type schemaDbProperty struct {
+	Url     string        `piper:"url" validate:"required" desc:"the url of database"`
+	Pool    int           `piper:"pool" validate:"min=1,max=100"`
+	Timeout time.Duration `piper:"timeout"`
+}
+
+func (*schemaDbProperty) Prefix() string {
+	return "piper.db"
+}
+
+func TestConfigSchema(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "config schema test")
+}
+
+var _ = Describe("config schema", func() {
+	props := []ConfigProperty{
+		&ApplicationProperty{},
+		&schemaDbProperty{Pool: 10, Timeout: 3 * time.Second},
+	}
+
+	It("json schema", func() {
+		content, err := GenerateConfigSchema(props)
+		Expect(err).NotTo(HaveOccurred())
+
+		var schema map[string]any
+		Expect(json.Unmarshal(content, &schema)).To(Succeed())
+		piperSchema := schema["properties"].(map[string]any)["piper"].(map[string]any)
+		piperProps := piperSchema["properties"].(map[string]any)
+		Expect(piperProps).To(HaveKey("application"))
+
+		db := piperProps["db"].(map[string]any)
+		Expect(db["required"]).To(Equal([]any{"url"}))
+		dbProps := db["properties"].(map[string]any)
+		Expect(dbProps["url"]).To(Equal(map[string]any{
+			"type":        "string",
+			"description": "the url of database",
+		}))
+		Expect(dbProps["pool"]).To(Equal(map[string]any{
+			"type":    "integer",
+			"default": float64(10),
+			"minimum": float64(1),
+			"maximum": float64(100),
+		}))
+		Expect(dbProps["timeout"]).To(Equal(map[string]any{
+			"type":    "string",
+			"format":  "duration",
+			"default": "3s",
+		}))
+	})
+	It("markdown docs", func() {
+		content, err := GenerateConfigDocs(props)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("" +
+			"| Key | Type | Default | Description |\n" +
+			"| --- | --- | --- | --- |\n" +
+			"| `piper.application.name` | string |  | the name of application |\n" +
+			"| `piper.db.pool` | integer | `10` |  |\n" +
+			"| `piper.db.timeout` | string | `3s` |  |\n" +
+			"| `piper.db.url` | string |  | the url of database |\n"))
+	})
+})
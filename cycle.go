@@ -18,24 +18,30 @@ import (
 	"bytes"
 )
 
-// cycleDepError represents error when cycle dependencies found in graph nodes.
-type cycleDepError struct {
-	nodes []*graphNode
+// CycleDependencyError represents error when cycle dependencies found in graph nodes.
+type CycleDependencyError struct {
+	// Chain is the names of providers in the cycle, the first one is the same as the
+	// last one.
+	Chain []string
 }
 
-func (e cycleDepError) Error() string {
+func (e *CycleDependencyError) Error() string {
 	errMsgBuffer := new(bytes.Buffer)
 	errMsgBuffer.WriteString("cycle dependencies found: \n\t")
-	for k, v := range e.nodes {
+	for k, v := range e.Chain {
 		if k > 0 {
 			errMsgBuffer.WriteString("\n\tdepends on ")
 		}
-		errMsgBuffer.WriteString(v.name)
+		errMsgBuffer.WriteString(v)
 	}
 
 	return errMsgBuffer.String()
 }
 
+func (e *CycleDependencyError) Is(target error) bool {
+	return target == ErrWiring
+}
+
 // cycleDependencyCheck checks if the node is cycle dependency for given dependency chain.
 func cycleDependencyCheck(depChain []*graphNode, nodeToCheck *graphNode) error {
 	for _, node := range depChain {
@@ -43,11 +49,8 @@ func cycleDependencyCheck(depChain []*graphNode, nodeToCheck *graphNode) error {
 			continue
 		}
 
-		nodes := make([]*graphNode, 0)
-		nodes = append(nodes, depChain...)
-		nodes = append(nodes, nodeToCheck)
-		return cycleDepError{
-			nodes: nodes,
+		return &CycleDependencyError{
+			Chain: nodeNames(depChain, nodeToCheck),
 		}
 	}
 
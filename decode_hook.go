@@ -0,0 +1,153 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DecodeHook converts the data in configuration into the target type when
+// unmarshalling configuration. The data should be returned as it is if the hook
+// cannot handle the given types.
+type DecodeHook func(from reflect.Type, to reflect.Type, data any) (any, error)
+
+var (
+	stringType          = reflect.TypeOf("")
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// StringConverter creates a DecodeHook which converts string in configuration into
+// T with the given func. For example:
+//
+//	env.AddDecodeHook(piper.StringConverter(func(s string) (Level, error) {
+//	    return parseLevel(s)
+//	}))
+func StringConverter[T any](convert func(string) (T, error)) DecodeHook {
+	targetType := reflect.TypeOf((*T)(nil)).Elem()
+
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if from != stringType || to != targetType {
+			return data, nil
+		}
+
+		return convert(data.(string))
+	}
+}
+
+// defaultDecodeHooks returns built-in decode hooks for durations, byte sizes, urls,
+// ips and all types which implement encoding.TextUnmarshaler.
+func defaultDecodeHooks() []DecodeHook {
+	return []DecodeHook{
+		StringConverter(time.ParseDuration),
+		StringConverter(ParseByteSize),
+		StringConverter(func(s string) (url.URL, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return url.URL{}, err
+			}
+			return *u, nil
+		}),
+		StringConverter(func(s string) (net.IP, error) {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address: %s", s)
+			}
+			return ip, nil
+		}),
+		textUnmarshalerHook,
+	}
+}
+
+// textUnmarshalerHook decodes string into the types implemented encoding.TextUnmarshaler,
+// this is useful for enums.
+func textUnmarshalerHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from != stringType || !reflect.PtrTo(to).Implements(textUnmarshalerType) {
+		return data, nil
+	}
+
+	value := reflect.New(to)
+	err := value.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(data.(string)))
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Elem().Interface(), nil
+}
+
+// locationHook decodes string into *time.Location field. The location is set into the
+// field directly, since mapstructure decodes pointers by copying the values they point
+// to, and copying time.Location breaks the lazy initialization of time.Local and the
+// identity of time.UTC.
+func locationHook(from reflect.Value, to reflect.Value) (any, error) {
+	if from.Kind() == reflect.Map && to.Kind() == reflect.Struct && to.CanSet() {
+		clearLocations(from.Interface(), to)
+		return from.Interface(), nil
+	}
+	if from.Type() != stringType || to.Type() != reflect.PtrTo(locationType) ||
+		!to.CanSet() {
+		return from.Interface(), nil
+	}
+
+	loc, err := time.LoadLocation(from.String())
+	if err != nil {
+		return nil, err
+	}
+	to.Set(reflect.ValueOf(loc))
+
+	// the typed nil pointer makes mapstructure keep the location set above
+	return (*time.Location)(nil), nil
+}
+
+// clearLocations clears the *time.Location fields of struct which are in config.
+// mapstructure decodes into the value of non-nil struct pointer, which should never
+// happen to locations, e.g. time.UTC used as default value.
+func clearLocations(input any, value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		if !structField.IsExported() || structField.Type != reflect.PtrTo(locationType) {
+			continue
+		}
+
+		name := strings.Split(structField.Tag.Get(piper), ",")[0]
+		if len(name) == 0 {
+			name = structField.Name
+		}
+		if _, ok := lookupRelaxed(input, name); ok {
+			value.Field(i).Set(reflect.Zero(structField.Type))
+		}
+	}
+}
+
+// composeDecodeHooks composes all the given hooks into one mapstructure decode hook.
+func composeDecodeHooks(hooks []DecodeHook) mapstructure.DecodeHookFunc {
+	funcs := make([]mapstructure.DecodeHookFunc, 0, len(hooks))
+	for _, h := range hooks {
+		funcs = append(funcs, mapstructure.DecodeHookFuncType(h))
+	}
+
+	funcs = append(funcs, mapstructure.DecodeHookFuncValue(locationHook))
+	// strings from environment variables can be decoded into slices
+	funcs = append(funcs, mapstructure.StringToSliceHookFunc(","))
+
+	return mapstructure.ComposeDecodeHookFunc(funcs...)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type decodeTestLevel int
+
+func (l *decodeTestLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+type decodeTestName string
+
+type decodeTestProperty struct {
+	Timeout  time.Duration   `piper:"timeout"`
+	MaxSize  ByteSize        `piper:"max-size"`
+	Endpoint *url.URL        `piper:"endpoint"`
+	Ip       net.IP          `piper:"ip"`
+	Location *time.Location  `piper:"location"`
+	Level    decodeTestLevel `piper:"level"`
+	Name     decodeTestName  `piper:"name"`
+}
+
+func TestDecodeHook(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "decode hook test")
+}
+
+var _ = Describe("decode hook", func() {
+	It("built-in and custom hooks", func() {
+		env := newAppEnv()
+		env.AddDecodeHook(StringConverter(func(s string) (decodeTestName, error) {
+			return decodeTestName(strings.ToUpper(s)), nil
+		}))
+		Expect(env.MergeConfigMap(map[string]any{
+			"decode": map[string]any{
+				"timeout":  "30s",
+				"max-size": "10MB",
+				"endpoint": "https://example.com/api",
+				"ip":       "127.0.0.1",
+				"location": "UTC",
+				"level":    "high",
+				"name":     "piper",
+			},
+		})).To(Succeed())
+
+		var prop decodeTestProperty
+		Expect(env.Unmarshal("decode", &prop)).To(Succeed())
+		Expect(prop.Timeout).To(Equal(30 * time.Second))
+		Expect(prop.MaxSize).To(Equal(10 * MegaByte))
+		Expect(prop.Endpoint.Host).To(Equal("example.com"))
+		Expect(prop.Ip.Equal(net.IPv4(127, 0, 0, 1))).To(BeTrue())
+		Expect(prop.Location).To(BeIdenticalTo(time.UTC))
+		Expect(prop.Level).To(Equal(decodeTestLevel(2)))
+		Expect(prop.Name).To(Equal(decodeTestName("PIPER")))
+	})
+	It("invalid value", func() {
+		env := newAppEnv()
+		Expect(env.MergeConfigMap(map[string]any{
+			"decode": map[string]any{"level": "medium"},
+		})).To(Succeed())
+
+		var prop decodeTestProperty
+		Expect(env.Unmarshal("decode", &prop)).NotTo(Succeed())
+	})
+	It("keep location pointers", func() {
+		env := newAppEnv()
+		Expect(env.MergeConfigMap(map[string]any{
+			"decode": map[string]any{
+				"location": "Local",
+				"zones":    map[string]any{"utc": "UTC"},
+			},
+		})).To(Succeed())
+
+		var prop struct {
+			Location *time.Location            `piper:"location"`
+			Zones    map[string]*time.Location `piper:"zones"`
+			Fallback *time.Location            `piper:"fallback"`
+		}
+		prop.Location = time.UTC
+		prop.Fallback = time.UTC
+		Expect(env.Unmarshal("decode", &prop)).To(Succeed())
+		Expect(prop.Location).To(BeIdenticalTo(time.Local))
+		Expect(prop.Zones["utc"]).To(BeIdenticalTo(time.UTC))
+		Expect(prop.Fallback).To(BeIdenticalTo(time.UTC))
+		Expect(time.UTC.String()).To(Equal("UTC"))
+	})
+})
@@ -15,13 +15,17 @@
 package piper
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
 	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -29,88 +33,154 @@ var (
 	singleton   *depTree
 )
 
+// depTree is the container of providers. Wiring and resolving are guarded by mutex,
+// and the resolved nodes are published atomically, so retrieving needs no lock once
+// the nodes have been instantiated.
 type depTree struct {
-	providers       map[providerKey][]*graphNode
+	mu              sync.Mutex
+	providers       map[ProviderKey][]*graphNode
 	unresolvedNodes []*graphNode
-	graphNodes      []*graphNode
-	options         map[string][]*WireOption
-	profile         string
+	// graphNodes holds the []*graphNode published after resolved
+	graphNodes atomic.Value
+	options    map[string][]*WireOption
+	profile    string
+	errs       []error
+	generated  map[string]GeneratedProvider
+	graphs     map[string]*GeneratedGraph
+	// graphOrder is the nodes in the order of generated graph if it was used
+	graphOrder []*graphNode
+
+	// stacks are the nodes being instantiated in each goroutine, keyed by goroutine id
+	stacksMu sync.Mutex
+	stacks   map[int64][]*graphNode
 }
 
 // graphNode represents a node in dependencies graph.
 type graphNode struct {
 	id        string
 	name      string
+	caller    string
 	ctorType  reflect.Type
 	ctorValue reflect.Value
 	provided  any
 
 	resolved     bool
-	instantiated bool
+	failed       bool
 	isCollection bool
 	dependencies []*graphNode
+
+	// mu guards owner and done, owner is the id of goroutine instantiating the node,
+	// and done will be closed when it finishes. state is 1 once instantiated.
+	mu    sync.Mutex
+	owner int64
+	done  chan struct{}
+	state uint32
+}
+
+// isInstantiated checks if the node has been instantiated, it is safe to be called
+// concurrently.
+func (n *graphNode) isInstantiated() bool {
+	return atomic.LoadUint32(&n.state) == 1
+}
+
+// markInstantiated marks the node instantiated after provided value set.
+func (n *graphNode) markInstantiated() {
+	atomic.StoreUint32(&n.state, 1)
 }
 
 // _depTree will return the singleton global depTree to use.
 func _depTree() *depTree {
 	onceDepTree.Do(func() {
-		singleton = &depTree{
-			providers:       make(map[providerKey][]*graphNode),
-			unresolvedNodes: make([]*graphNode, 0),
-			graphNodes:      make([]*graphNode, 0),
-			options:         make(map[string][]*WireOption),
-		}
+		singleton = newDepTree()
 	})
 
 	return singleton
 }
 
+func newDepTree() *depTree {
+	return &depTree{
+		providers:       make(map[ProviderKey][]*graphNode),
+		unresolvedNodes: make([]*graphNode, 0),
+		options:         make(map[string][]*WireOption),
+		generated:       make(map[string]GeneratedProvider),
+		graphs:          make(map[string]*GeneratedGraph),
+		stacks:          make(map[int64][]*graphNode),
+	}
+}
+
 // Wire registers field or func provider into global container. Then the container will
 // resolve the dependecies for these providers. For example:
 //
-//  func newA(some SomeType) *TypeA {
-//      return &TypeA {
-//          ...
-//      }
-//  }
+//	func newA(some SomeType) *TypeA {
+//	    return &TypeA {
+//	        ...
+//	    }
+//	}
 //
-//  piper.Wire(newA)
+//	piper.Wire(newA)
 //
 // it also supports to wire multiple providers:
 //
-//  piper.Wire(newA, &TypeB{}, ...)
+//	piper.Wire(newA, &TypeB{}, ...)
+//
+// Invalid providers are recorded with the location of caller instead of panicking, use
+// Validate to check them, or they will be returned when the application starts. Wire
+// should be called before the application starts, e.g. in init, it panics with
+// RegistrationError once the dependencies have been resolved.
 func Wire(providers ...any) {
-	_depTree().wire(providers...)
+	caller := callerLocation(1)
+	for _, p := range providers {
+		_depTree().register(caller, p)
+	}
 }
 
 // WireWithOption wires with options. The order of options keep the same with the order
 // of in parameters of provider if it was a func, and wire option should be the last one.
 // The field should have only wire out option. For example:
 //
-//  func newSomething(paramA typeA, paramB typeB) *Something {
-//      return &Something{
-//          ...
-//      }
-//  }
+//	func newSomething(paramA typeA, paramB typeB) *Something {
+//	    return &Something{
+//	        ...
+//	    }
+//	}
 //
-//  piper.WireWithOption(newSomething, piper.Name("MyA"))
+//	piper.WireWithOption(newSomething, piper.Name("MyA"))
 //
 // or use can set default value for one in parameter:
 //
-//  piper.WireWithOption(newSomething, piper.Default(defaultA))
+//	piper.WireWithOption(newSomething, piper.Default(defaultA))
 //
 // the field provider can only use:
 //
-//  piper.WireWithOption(&Otherthing{...}, piper.OutName("MyThing"))
+//	piper.WireWithOption(&Otherthing{...}, piper.OutName("MyThing"))
 func WireWithOption(provider any, opts ...*WireOption) {
-	_depTree().wireWithOption(provider, opts...)
+	_depTree().register(callerLocation(1), provider, opts...)
+}
+
+// Validate returns the errors found in the global container so far, such as invalid
+// providers wired, or nil if no error found. Wire never panics for invalid providers,
+// the errors will be returned here and when the application starts.
+func Validate() error {
+	tree := _depTree()
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	if len(tree.errs) == 0 {
+		return nil
+	}
+
+	return newWiringErrors(append([]error(nil), tree.errs...))
 }
 
 // Retrieve gets all the values for the given type with order.
 func Retrieve[T any](tp reflect.Type) []T {
+	return retrieveFrom[T](_depTree(), tp)
+}
+
+// retrieveFrom gets all the values of the given type in tree as T.
+func retrieveFrom[T any](tree *depTree, tp reflect.Type) []T {
 	var fields = make([]T, 0)
-	for _, v := range _depTree().retrieve(tp) {
-		fields = append(fields, v)
+	for _, v := range tree.retrieve(tp) {
+		fields = append(fields, v.(T))
 	}
 
 	return fields
@@ -122,28 +192,54 @@ func LazyLoad() {
 }
 
 func (c *depTree) wire(providers ...any) {
+	caller := callerLocation(1)
 	for _, p := range providers {
-		c.wireWithOption(p)
+		c.register(caller, p)
 	}
 }
 
 func (c *depTree) wireWithOption(provider any, opts ...*WireOption) {
+	c.register(callerLocation(1), provider, opts...)
+}
+
+// register registers the provider wired at caller. The invalid provider will be
+// recorded, and reported together with the other wiring errors when resolving
+// dependencies.
+func (c *depTree) register(caller string, provider any, opts ...*WireOption) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if provider == nil {
-		panic("pre process provider error: cannot be nil")
+		c.errs = append(c.errs, newRegistrationError("nil", caller,
+			errors.New("provider cannot be nil")))
+		return
+	}
+
+	// the providers wired after resolved would never be resolved or retrieved
+	if c.nodes() != nil {
+		panic(newRegistrationError(reflect.TypeOf(provider).String(), caller,
+			errors.New("provider cannot be wired after dependencies resolved")))
 	}
 
 	if _, ok := provider.(*WireOption); ok {
-		panic("provider cannot be wire option")
+		c.errs = append(c.errs, newRegistrationError(reflect.TypeOf(provider).String(),
+			caller, errors.New("provider cannot be wire option")))
+		return
 	}
 
+	var err error
 	providerType := reflect.TypeOf(provider)
 	providerKind := providerType.Kind()
 	if providerKind == reflect.Func {
-		c.buildFuncNode(provider, opts...)
+		err = c.buildFuncNode(caller, provider, opts...)
 	} else {
-		c.buildFieldNode(provider, opts...)
+		err = c.buildFieldNode(caller, provider, opts...)
+	}
+	if err != nil {
+		c.errs = append(c.errs, err)
 	}
 }
+
 func (c *depTree) validateWireOption(pType reflect.Type,
 	actualName string, opts []*WireOption) error {
 	if len(opts) == 0 {
@@ -214,34 +310,32 @@ func (c *depTree) requiredOptValue(opt *WireOption) bool {
 	return opt != nil && opt.required
 }
 
-func (c *depTree) buildFieldNode(provider any, opts ...*WireOption) {
+func (c *depTree) buildFieldNode(caller string, provider any, opts ...*WireOption) error {
+	fieldType := reflect.TypeOf(provider)
 	if len(opts) > 1 || len(opts) == 1 &&
 		(!opts[0].isWireOut() || opts[0].validate() != nil) {
-		panic("pre process instantiated provider error: only one " +
-			"wire out option can be passed to wire")
+		return newRegistrationError(fieldType.String(), caller,
+			errors.New("only one wire out option can be passed to wire"))
 	}
 
-	fieldType := reflect.TypeOf(provider)
 	kind := fieldType.Kind()
 	if kind != reflect.Ptr && kind != reflect.Chan &&
 		kind != reflect.Map && kind != reflect.Slice {
-		panic("pre process instantiated provider error: " +
-			"only non nil pointer type, chan, map, slice can be passed to wire")
+		return newRegistrationError(fieldType.String(), caller, errors.New(
+			"only non nil pointer type, chan, map, slice can be passed to wire"))
 	}
 
 	if (kind == reflect.Ptr && fieldType.Elem().Kind() != reflect.Struct ||
 		kind == reflect.Chan || kind == reflect.Map ||
 		kind == reflect.Slice && fieldType.Elem().Kind() != reflect.Struct) &&
 		len(opts) == 0 {
-		panic("pre process instantiated provider error: " +
-			"primitive type should be provided with 'WireWithOption'")
-		return
+		return newRegistrationError(fieldType.String(), caller,
+			errors.New("primitive type should be provided with 'WireWithOption'"))
 	}
 
 	field, err := ParseField(provider)
 	if err != nil {
-		Panicf("invalid field: %v", field)
-		return
+		return newRegistrationError(fieldType.String(), caller, err)
 	}
 
 	var alias string
@@ -258,32 +352,32 @@ func (c *depTree) buildFieldNode(provider any, opts ...*WireOption) {
 	c.options[uuid] = opts
 
 	savedNodes = append(savedNodes, &graphNode{
-		id:           uuid,
-		name:         field.ActualName(),
-		resolved:     true,
-		instantiated: true,
-		provided:     provider,
+		id:       uuid,
+		name:     field.ActualName(),
+		caller:   caller,
+		resolved: true,
+		state:    1,
+		provided: provider,
 	})
 	c.providers[key] = savedNodes
+
+	return nil
 }
 
-func (c *depTree) buildFuncNode(provider any, opts ...*WireOption) {
+func (c *depTree) buildFuncNode(caller string, provider any, opts ...*WireOption) error {
 	providerType := reflect.TypeOf(provider)
 	if providerType.NumOut() != 1 {
-		Panicf("pre process provider error: no or more than "+
-			"one out parameter for the given provider: %v", providerType)
-		return
+		return newRegistrationError(providerType.String(), caller,
+			errors.New("no or more than one out parameter for the given provider"))
 	}
 
 	fn, err := ParseFunc(provider)
 	if err != nil {
-		Panicf("parse func error: %v", err)
-		return
+		return newRegistrationError(providerType.String(), caller, err)
 	}
 
 	if err := c.validateWireOption(providerType, fn.ActualName(), opts); err != nil {
-		Panicf("validate wire option error: %v", err)
-		return
+		return newRegistrationError(fn.ActualName(), caller, err)
 	}
 
 	var alias string
@@ -304,6 +398,7 @@ func (c *depTree) buildFuncNode(provider any, opts ...*WireOption) {
 	newNode := &graphNode{
 		id:        uuid,
 		name:      fn.ActualName(),
+		caller:    caller,
 		resolved:  false,
 		ctorType:  fn.FuncType,
 		ctorValue: fn.FuncValue,
@@ -313,10 +408,12 @@ func (c *depTree) buildFuncNode(provider any, opts ...*WireOption) {
 
 	// save unresolved node
 	c.unresolvedNodes = append(c.unresolvedNodes, newNode)
+
+	return nil
 }
 
-func (c *depTree) buildKey(field *Field, alias string) providerKey {
-	return providerKey{
+func (c *depTree) buildKey(field *Field, alias string) ProviderKey {
+	return ProviderKey{
 		isPointer: field.IsPointer,
 		name:      field.ActualName(),
 		alias:     alias,
@@ -348,10 +445,13 @@ func (c *depTree) active(node *graphNode) bool {
 	return false
 }
 
-func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) error {
-	// if the node is resolved, do nothing
-	if nodeToResolve.resolved {
-		return nil
+// resolveNode resolves the dependencies of node. It goes on resolving the rest of in
+// parameters when one of them failed, and records all the errors found in the tree, so
+// they can be reported at once. It returns false if the node cannot be resolved.
+func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) bool {
+	// if the node is resolved or failed, do nothing
+	if nodeToResolve.resolved || nodeToResolve.failed {
+		return nodeToResolve.resolved
 	}
 
 	ctorType := nodeToResolve.ctorType
@@ -359,12 +459,15 @@ func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) e
 	// if no input parameters, means this node has no dependencies
 	if numIn == 0 {
 		nodeToResolve.resolved = true
-		return nil
+		return true
 	}
 
 	opts := c.options[nodeToResolve.id]
 	inOpts, _ := c.splitOptions(opts)
 
+	// the errors of node itself, the errors of dependencies are recorded by themselves
+	errs := make([]error, 0)
+	failed := false
 	for i := 0; i < ctorType.NumIn(); i++ {
 		inType := ctorType.In(i)
 		kind := inType.Kind()
@@ -373,7 +476,8 @@ func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) e
 		}
 		field, err := ParseFieldType(inType)
 		if err != nil {
-			return errors.New(fmt.Sprintf("%s: %s", err, nodeToResolve.name))
+			errs = append(errs, newRegistrationError(nodeToResolve.name, nodeToResolve.caller, err))
+			continue
 		}
 
 		var inOpt *WireOption
@@ -398,7 +502,11 @@ func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) e
 					// resolve child node
 					if err := c.resolveChildNode(node, append(depChain,
 						nodeToResolve)); err != nil {
-						return err
+						errs = append(errs, err)
+					}
+					if !node.resolved {
+						failed = true
+						continue
 					}
 					collectionNode.dependencies = append(
 						collectionNode.dependencies, node)
@@ -418,19 +526,26 @@ func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) e
 
 					// no primary node found
 					if primaryNode == nil {
-						return newMultiDepError(key, nodeToResolve.name)
+						errs = append(errs, newMultipleDependencyError(key, nodeToResolve,
+							depChain, nodes))
+						continue
 					}
 				} else {
 					primaryNode = nodes[0]
 				}
 
 				if !c.active(primaryNode) {
-					return newNoDepError(key, nodeToResolve.name)
+					errs = append(errs, newNoDependencyError(key, nodeToResolve, depChain))
+					continue
 				}
 
 				if err := c.resolveChildNode(primaryNode, append(depChain,
 					nodeToResolve)); err != nil {
-					return err
+					errs = append(errs, err)
+				}
+				if !primaryNode.resolved {
+					failed = true
+					continue
 				}
 				nodeToResolve.dependencies = append(nodeToResolve.dependencies, primaryNode)
 			}
@@ -438,18 +553,22 @@ func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) e
 			// dependency is not found, try to get default
 			defVal := c.defaultOptValue(inOpt)
 			if c.requiredOptValue(inOpt) || defVal == nil {
-				return newNoDepError(key, nodeToResolve.name)
+				errs = append(errs, newNoDependencyError(key, nodeToResolve, depChain))
+				continue
 			}
 
 			defValType := reflect.TypeOf(defVal)
 			if defValType.Kind() == reflect.Func {
 				// TODO: add default func support
-				return errors.New("default value cannot be func")
+				errs = append(errs, newRegistrationError(nodeToResolve.name,
+					nodeToResolve.caller, errors.New("default value cannot be func")))
+				continue
 			}
 
 			defField, err := ParseField(defVal)
 			if err != nil {
-				return err
+				errs = append(errs, newRegistrationError(nodeToResolve.name, nodeToResolve.caller, err))
+				continue
 			}
 
 			var typeMatched bool
@@ -461,63 +580,115 @@ func (c *depTree) resolveNode(nodeToResolve *graphNode, depChain []*graphNode) e
 			}
 
 			if !defField.Equal(field) && !typeMatched {
-				return newDefValueMismatchError(defField, field, nodeToResolve.name)
+				errs = append(errs, newDefaultValueMismatchError(defField, field,
+					nodeToResolve, depChain))
+				continue
 			}
 
+			// the default value has no options, and it may not be a pointer
 			nodeToResolve.dependencies = append(nodeToResolve.dependencies, &graphNode{
-				id:           c.buildUuid(defVal),
-				name:         key.name,
-				resolved:     true,
-				instantiated: true,
-				provided:     defVal,
+				name:     key.name,
+				resolved: true,
+				state:    1,
+				provided: defVal,
 			})
 		}
 	}
 
+	if len(errs) != 0 || failed {
+		c.errs = append(c.errs, errs...)
+		nodeToResolve.failed = true
+		nodeToResolve.dependencies = nil
+		return false
+	}
 	nodeToResolve.resolved = true
 
-	return nil
+	return true
 }
 
+// resolveFunc resolves the dependencies of the func which is not wired, such as the
+// run func of command, after the tree resolved. The errors will not be recorded in the
+// tree, and the returned node can be instantiated to call the func.
+func (c *depTree) resolveFunc(name string, fn reflect.Value,
+	opts ...*WireOption) (*graphNode, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	node := &graphNode{
+		id:        name,
+		name:      name,
+		ctorType:  fn.Type(),
+		ctorValue: fn,
+	}
+	c.options[node.id] = opts
+	defer delete(c.options, node.id)
+
+	count := len(c.errs)
+	if c.resolveNode(node, nil) {
+		return node, nil
+	}
+	errs := append([]error(nil), c.errs[count:]...)
+	c.errs = c.errs[:count]
+
+	return nil, newWiringErrors(errs)
+}
+
+// resolveChildNode resolves the child node in the chain. It returns the error if the
+// child node forms a cycle with the chain.
 func (c *depTree) resolveChildNode(node *graphNode, chain []*graphNode) error {
-	if !node.resolved {
+	if !node.resolved && !node.failed {
 		if err := cycleDependencyCheck(chain, node); err != nil {
 			return err
 		}
 
-		if err := c.resolveNode(node, chain); err != nil {
-			return err
-		}
+		c.resolveNode(node, chain)
 	}
 
 	return nil
 }
 
+// resolveDependencies resolves all the wired providers. It returns all the errors found
+// when wiring and resolving, and the errors will be aggregated in WiringErrors if more
+// than one found.
 func (c *depTree) resolveDependencies() error {
-	for _, nodeToResolve := range c.unresolvedNodes {
-		if err := c.resolveNode(nodeToResolve, make([]*graphNode, 0)); err != nil {
-			return err
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// resolve at runtime unless the graph generated by `piper gen` can be used
+	if len(c.errs) != 0 || !c.useGraph() {
+		for _, nodeToResolve := range c.unresolvedNodes {
+			c.resolveNode(nodeToResolve, make([]*graphNode, 0))
 		}
 	}
 
+	if len(c.errs) != 0 {
+		return newWiringErrors(c.errs)
+	}
+
 	// clear unused resource
 	c.unresolvedNodes = nil
 
+	graphNodes := make([]*graphNode, 0)
 	for _, nodes := range c.providers {
-		for _, node := range nodes {
-			c.graphNodes = append(c.graphNodes, node)
-		}
+		graphNodes = append(graphNodes, nodes...)
 	}
+	c.graphNodes.Store(graphNodes)
 
 	return nil
 }
 
+// nodes returns the resolved nodes, or nil if not resolved.
+func (c *depTree) nodes() []*graphNode {
+	nodes, _ := c.graphNodes.Load().([]*graphNode)
+	return nodes
+}
+
 func (c *depTree) matchType(node *graphNode, fieldType reflect.Type) bool {
 	ctorType := node.ctorType
 	var outType reflect.Type
 	if ctorType != nil {
 		outType = ctorType.Out(0)
-	} else if node.instantiated {
+	} else if node.isInstantiated() {
 		outType = reflect.TypeOf(node.provided)
 	} else {
 		return false
@@ -540,78 +711,216 @@ func (c *depTree) matchType(node *graphNode, fieldType reflect.Type) bool {
 	return outType.Implements(fieldType)
 }
 
+// instantiate instantiates the node after its dependencies. The node will be
+// instantiated exactly once even retrieved concurrently, the other goroutines wait
+// until it finishes. If a constructor retrieves the node being instantiated in the
+// same goroutine, it panics with CycleDependencyError instead of waiting forever.
 func (c *depTree) instantiate(node *graphNode) {
-	if !node.resolved {
+	if !node.resolved || node.isInstantiated() {
 		return
 	}
 
+	c.instantiateIn(goroutineId(), node)
+}
+
+// instantiateIn instantiates the node in the goroutine with id. The id is passed down
+// to the dependencies, so it is looked up once for each retrieval instead of each node.
+func (c *depTree) instantiateIn(id int64, node *graphNode) {
+	if !node.resolved || node.isInstantiated() {
+		return
+	}
+
+	node.mu.Lock()
+	for node.owner != 0 {
+		if node.owner == id {
+			node.mu.Unlock()
+			panic(c.reentryError(id, node))
+		}
+		done := node.done
+		node.mu.Unlock()
+		<-done
+		node.mu.Lock()
+	}
+	// check again, it may have been instantiated while waiting
+	if node.isInstantiated() {
+		node.mu.Unlock()
+		return
+	}
+	node.owner = id
+	node.done = make(chan struct{})
+	node.mu.Unlock()
+
+	c.pushInstantiating(id, node)
+	defer func() {
+		c.popInstantiating(id)
+		node.mu.Lock()
+		node.owner = 0
+		close(node.done)
+		node.mu.Unlock()
+	}()
+
 	for _, dep := range node.dependencies {
-		if dep.instantiated {
-			continue
+		c.instantiateIn(id, dep)
+	}
+
+	c.build(node)
+}
+
+func (c *depTree) pushInstantiating(id int64, node *graphNode) {
+	c.stacksMu.Lock()
+	defer c.stacksMu.Unlock()
+	c.stacks[id] = append(c.stacks[id], node)
+}
+
+func (c *depTree) popInstantiating(id int64) {
+	c.stacksMu.Lock()
+	defer c.stacksMu.Unlock()
+	if stack := c.stacks[id]; len(stack) > 1 {
+		c.stacks[id] = stack[:len(stack)-1]
+	} else {
+		delete(c.stacks, id)
+	}
+}
+
+// reentryError returns the cycle from the node to the one retrieving it again.
+func (c *depTree) reentryError(id int64, node *graphNode) error {
+	c.stacksMu.Lock()
+	defer c.stacksMu.Unlock()
+
+	stack := c.stacks[id]
+	for i, n := range stack {
+		if n == node {
+			stack = stack[i:]
+			break
 		}
-		c.instantiate(dep)
 	}
 
-	in := make([]reflect.Value, 0)
+	return &CycleDependencyError{
+		Chain: nodeNames(stack, node),
+	}
+}
+
+// goroutineId returns the id of current goroutine parsed from the stack header, such
+// as "goroutine 18 [running]:". It is slow, so it should not be called for each node.
+func goroutineId() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i > 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseInt(string(buf), 10, 64)
+
+	return id
+}
+
+// build instantiates the node whose dependencies have all been instantiated.
+func (c *depTree) build(node *graphNode) {
 	if node.isCollection {
-		for _, child := range node.dependencies {
-			if child.instantiated {
+		node.markInstantiated()
+		return
+	}
+	defer _timeline.track(phaseProvider, node.name)()
+
+	// prefer the provider generated by `piper gen` which requires no reflection
+	c.mu.Lock()
+	gen, ok := c.generated[node.name]
+	c.mu.Unlock()
+	if ok {
+		args := make([]any, 0, len(node.dependencies))
+		for _, depNode := range node.dependencies {
+			if !depNode.isCollection {
+				args = append(args, depNode.provided)
 				continue
 			}
-			c.instantiate(child)
+			collection := make([]any, 0, len(depNode.dependencies))
+			for _, child := range depNode.dependencies {
+				collection = append(collection, child.provided)
+			}
+			args = append(args, collection)
 		}
-		node.instantiated = true
+		node.provided = gen(args)
+		node.markInstantiated()
 		return
-	} else {
-		// the number of in parameters is equal to number of dependencies
-		numIn := node.ctorType.NumIn()
-		for i := 0; i < numIn; i++ {
-			depNode := node.dependencies[i]
-			if depNode.isCollection {
-				collectionIn := reflect.New(depNode.ctorType).Elem()
-				for _, child := range depNode.dependencies {
-					childKind := reflect.TypeOf(child.provided).Kind()
-					childValue := reflect.ValueOf(child.provided)
-					if childKind == reflect.Slice {
-						collectionIn = reflect.AppendSlice(collectionIn, childValue)
-					} else {
-						collectionIn = reflect.Append(collectionIn, childValue)
-					}
+	}
+
+	// the number of in parameters is equal to number of dependencies
+	in := make([]reflect.Value, 0)
+	numIn := node.ctorType.NumIn()
+	for i := 0; i < numIn; i++ {
+		depNode := node.dependencies[i]
+		if depNode.isCollection {
+			collectionIn := reflect.New(depNode.ctorType).Elem()
+			for _, child := range depNode.dependencies {
+				childKind := reflect.TypeOf(child.provided).Kind()
+				childValue := reflect.ValueOf(child.provided)
+				if childKind == reflect.Slice {
+					collectionIn = reflect.AppendSlice(collectionIn, childValue)
+				} else {
+					collectionIn = reflect.Append(collectionIn, childValue)
 				}
-				in = append(in, collectionIn)
-			} else {
-				in = append(in, reflect.ValueOf(depNode.provided))
 			}
+			in = append(in, collectionIn)
+		} else {
+			in = append(in, reflect.ValueOf(depNode.provided))
 		}
 	}
 
 	// instantiates the node with parameters
 	out := node.ctorValue.Call(in)
 	node.provided = out[0].Interface()
-	node.instantiated = true
+	node.markInstantiated()
 }
 
 func (c *depTree) retrieve(tp reflect.Type) []any {
-	var fields = make([]any, 0)
-	var orderedFields = make([]any, 0)
+	var values = make([]any, 0)
 
-	for _, node := range c.graphNodes {
+	for _, node := range c.nodes() {
 		if c.matchType(node, tp) {
-			if !node.instantiated {
-				c.instantiate(node)
-			}
+			c.instantiate(node)
 			// check again after instantiating
-			if node.instantiated {
-				if _, ok := node.provided.(Ordered); ok {
-					orderedFields = append(orderedFields, node.provided)
-				} else {
-					fields = append(fields, node.provided)
-				}
+			if node.isInstantiated() {
+				values = append(values, node.provided)
+			}
+		}
+	}
+
+	return sortByOrder(values)
+}
+
+// retrieveInstances gets the instantiated values for the given type with order. This
+// does not require dependencies to be resolved, so it can be used when resolving failed.
+func (c *depTree) retrieveInstances(tp reflect.Type) []any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var values = make([]any, 0)
+
+	for _, nodes := range c.providers {
+		for _, node := range nodes {
+			if node.isInstantiated() && c.matchType(node, tp) {
+				values = append(values, node.provided)
 			}
 		}
 	}
 
-	sort.Slice(orderedFields, func(i, j int) bool {
+	return sortByOrder(values)
+}
+
+// sortByOrder sorts the values which implement Ordered, and puts the others after them.
+func sortByOrder(values []any) []any {
+	var fields = make([]any, 0)
+	var orderedFields = make([]any, 0)
+
+	for _, v := range values {
+		if _, ok := v.(Ordered); ok {
+			orderedFields = append(orderedFields, v)
+		} else {
+			fields = append(fields, v)
+		}
+	}
+
+	sort.SliceStable(orderedFields, func(i, j int) bool {
 		return orderedFields[i].(Ordered).Order() < orderedFields[j].(Ordered).Order()
 	})
 
@@ -0,0 +1,164 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type concurrentRepo struct {
+}
+
+type concurrentService struct {
+	repo *concurrentRepo
+}
+
+type concurrentExtra struct {
+}
+
+type reentrantGetter interface {
+	Get() string
+}
+
+type reentrantService struct {
+}
+
+func (*reentrantService) Get() string {
+	return "reentrant"
+}
+
+var (
+	concurrentRepoCalls    int32
+	concurrentServiceCalls int32
+)
+
+func newConcurrentRepo() *concurrentRepo {
+	atomic.AddInt32(&concurrentRepoCalls, 1)
+	return &concurrentRepo{}
+}
+
+func newConcurrentService(repo *concurrentRepo) *concurrentService {
+	atomic.AddInt32(&concurrentServiceCalls, 1)
+	return &concurrentService{repo: repo}
+}
+
+func TestDepTree(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "dep tree test")
+}
+
+var _ = Describe("dep tree", func() {
+	It("instantiate once when retrieved concurrently", func() {
+		atomic.StoreInt32(&concurrentRepoCalls, 0)
+		atomic.StoreInt32(&concurrentServiceCalls, 0)
+		tree := newDepTree()
+		tree.wire(newConcurrentService, newConcurrentRepo)
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		services := make([]any, 16)
+		var wg sync.WaitGroup
+		for i := range services {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				values := tree.retrieve(reflect.TypeOf(&concurrentService{}))
+				if len(values) == 1 {
+					services[i] = values[0]
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		Expect(atomic.LoadInt32(&concurrentRepoCalls)).To(Equal(int32(1)))
+		Expect(atomic.LoadInt32(&concurrentServiceCalls)).To(Equal(int32(1)))
+		for _, s := range services {
+			Expect(s).To(BeIdenticalTo(services[0]))
+		}
+		Expect(services[0].(*concurrentService).repo).NotTo(BeNil())
+	})
+	It("reject wiring after resolved", func() {
+		tree := newDepTree()
+		tree.wire(newConcurrentRepo)
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		var err error
+		func() {
+			defer func() {
+				err, _ = recover().(error)
+			}()
+			tree.wire(&concurrentExtra{})
+		}()
+		var e *RegistrationError
+		Expect(errors.As(err, &e)).To(BeTrue())
+	})
+	It("panic when retrieved again by its own constructor", func() {
+		tree := newDepTree()
+		tree.wire(newConcurrentRepo, func(_ *concurrentRepo) *reentrantService {
+			tree.retrieve(reflect.TypeOf((*reentrantGetter)(nil)))
+			return &reentrantService{}
+		})
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		var err error
+		func() {
+			defer func() {
+				err, _ = recover().(error)
+			}()
+			tree.retrieve(reflect.TypeOf(&reentrantService{}))
+		}()
+		var e *CycleDependencyError
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Chain).To(HaveLen(2))
+
+		// the failed node can be retrieved in another goroutine without blocking
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer func() { _ = recover() }()
+			tree.retrieve(reflect.TypeOf(&reentrantService{}))
+		}()
+		Eventually(done).Should(BeClosed())
+	})
+	It("resolve func not wired", func() {
+		tree := newDepTree()
+		tree.wire(newConcurrentRepo)
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		var repo *concurrentRepo
+		node, err := tree.resolveFunc("command migrate", reflect.ValueOf(
+			func(r *concurrentRepo) error {
+				repo = r
+				return errors.New("migrate failed")
+			}))
+		Expect(err).NotTo(HaveOccurred())
+		tree.instantiate(node)
+		Expect(repo).NotTo(BeNil())
+		Expect(node.provided).To(MatchError("migrate failed"))
+
+		_, err = tree.resolveFunc("command seed", reflect.ValueOf(
+			func(_ *concurrentService) error { return nil }))
+		var e *NoDependencyError
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Node).To(Equal("command seed"))
+		Expect(tree.errs).To(BeEmpty())
+	})
+})
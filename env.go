@@ -16,19 +16,36 @@ package piper
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/coolerfall/slago"
 	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/afero"
 	"github.com/spf13/viper"
 )
 
 // AppEnv represents the environment for the whole lifecycle of application.
 type AppEnv struct {
 	// just wrap some functions of viper
-	vp      *viper.Viper
-	cliName string
+	vp          *viper.Viper
+	mu          sync.RWMutex
+	cliName     string
+	configPaths []string
+	configFiles []string
+	decodeHooks []DecodeHook
+	// resourceFs reads config and resources from os filesystem or embedded resources
+	resourceFs afero.Fs
+
+	// parent and prefix are used by the scoped view created with Sub
+	parent *AppEnv
+	prefix string
 }
 
 // newAppEnv creates a new instance of AppEnv which can be used to get
@@ -52,84 +69,458 @@ func newAppEnv() *AppEnv {
 		binDir = binPath[:index]
 	}
 
-	env := &AppEnv{
-		vp:      viper.New(),
-		cliName: cliName,
+	configPaths := []string{resourcesDir}
+	if wd != binDir {
+		configPaths = append(configPaths, filepath.Join(filepath.Dir(binPath), resourcesDir))
 	}
 
-	// we currently only support yaml config file
-	// TODO: filesystem
-	//env.vp.SetFs()
-	env.vp.SetConfigType("yml")
-	env.vp.AddConfigPath(resourcesDir)
-	if wd != binDir {
-		env.vp.AddConfigPath(filepath.Join(filepath.Dir(binPath), resourcesDir))
+	env := &AppEnv{
+		cliName:     cliName,
+		configPaths: configPaths,
+		resourceFs:  newResourceFs(nil),
 	}
-	// this is used for embedded file system
-	env.vp.AddConfigPath(fmt.Sprintf("/%s/%s", piper, resourcesDir))
+	env.vp = env.newViper()
+
+	return env
+}
+
+// newViper creates a new viper instance with default values.
+func (c *AppEnv) newViper() *viper.Viper {
+	vp := viper.New()
 
 	// set default value
-	env.vp.SetDefault(fmt.Sprintf("%s.application.name", piper), fmt.Sprintf("%s-app", piper))
+	vp.SetDefault(keyConfigName, "application")
+	vp.SetDefault(fmt.Sprintf("%s.application.name", piper), fmt.Sprintf("%s-app", piper))
+
+	return vp
+}
+
+// viper returns the viper instance currently used by this environment.
+func (c *AppEnv) viper() *viper.Viper {
+	if c.parent != nil {
+		return c.parent.viper()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.vp
+}
+
+// fork creates a fresh environment with the same config paths and profile, but
+// without any loaded configuration. This is used to reload configuration.
+func (c *AppEnv) fork() *AppEnv {
+	c.mu.RLock()
+	env := &AppEnv{
+		cliName:     c.cliName,
+		configPaths: c.configPaths,
+		decodeHooks: c.decodeHooks,
+		resourceFs:  c.resourceFs,
+	}
+	c.mu.RUnlock()
+	env.vp = env.newViper()
+	env.vp.Set(keyProfile, c.Profile())
+	env.vp.Set(keyConfigLocation, c.viper().GetStringSlice(keyConfigLocation))
+	env.vp.Set(keyConfigAdditionalLocation,
+		c.viper().GetStringSlice(keyConfigAdditionalLocation))
 
 	return env
 }
 
+// replaceWith replaces the configuration of current environment with the other one
+// and returns the replaced environment which can be used to roll back.
+func (c *AppEnv) replaceWith(other *AppEnv) *AppEnv {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	old := &AppEnv{
+		vp:          c.vp,
+		cliName:     c.cliName,
+		configPaths: c.configPaths,
+		configFiles: c.configFiles,
+		decodeHooks: c.decodeHooks,
+		resourceFs:  c.resourceFs,
+	}
+	c.vp = other.viper()
+	c.configFiles = other.ConfigFiles()
+
+	return old
+}
+
+// mergeWith reads the config file with given name in config paths, and merges all
+// the active documents and imports of it. Check configReader for the merge precedence.
 func (c *AppEnv) mergeWith(name string) error {
-	c.vp.SetConfigName(name)
+	filename, err := c.findConfigFile(name)
+	if err != nil {
+		return err
+	}
 
-	if err := c.vp.MergeInConfig(); err != nil {
+	reader := newConfigReader(c.Profile(), c.resourceFs)
+	docs, err := reader.read(filename)
+	if err != nil {
 		return err
 	}
 
-	return c.MergeConfigMap(c.vp.AllSettings())
+	c.mu.Lock()
+	c.configFiles = append(c.configFiles, reader.readFiles()...)
+	c.mu.Unlock()
+
+	for _, doc := range docs {
+		if err = c.MergeConfigMap(doc); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// expandMerge expands environment variables and merges config.
-func (c *AppEnv) expandMerge(cfg map[string]any, isChild bool) (map[string]any, error) {
-	newCfg := make(map[string]any)
+// ConfigSearchPaths returns the directories to search config files in order, the
+// config file found first will be used:
+//
+//  1. the directories in piper.config.location, set by --config-location flag or
+//     PIPER_CONFIG_LOCATION environment variable, separated by comma
+//  2. ./resources in working directory
+//  3. resources in the directory of executable
+//  4. $XDG_CONFIG_HOME/<app>, which is ~/.config/<app> by default
+//  5. /etc/<app>
+//  6. the directories in piper.config.additional-location, set by
+//     --config-additional-location flag or PIPER_CONFIG_ADDITIONAL_LOCATION
+//  7. the embedded resources
+//
+// The <app> is the name of executable.
+func (c *AppEnv) ConfigSearchPaths() []string {
+	if c.parent != nil {
+		return c.parent.ConfigSearchPaths()
+	}
 
-	for k, v := range cfg {
-		switch v.(type) {
-		case string:
-			newCfg[k] = ExpandEnv(v.(string))
+	paths := c.locations(keyConfigLocation)
+	paths = append(paths, c.configPaths...)
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if len(configHome) == 0 {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if len(configHome) != 0 {
+		paths = append(paths, filepath.Join(configHome, c.cliName))
+	}
+	paths = append(paths, filepath.Join("/etc", c.cliName))
+	paths = append(paths, c.locations(keyConfigAdditionalLocation)...)
+
+	// this is used for embedded file system
+	return append(paths, fmt.Sprintf("/%s/%s", piper, resourcesDir))
+}
+
+// locations gets directories with the given key from command line flag or
+// environment variable.
+func (c *AppEnv) locations(key string) []string {
+	value := c.viper().GetStringSlice(key)
+	if len(value) == 0 {
+		if env, ok := os.LookupEnv(envName(key)); ok {
+			value = strings.Split(env, ",")
+		}
+	}
+
+	locations := make([]string, 0, len(value))
+	for _, l := range value {
+		// the flag may be passed as --config-location a,b
+		for _, dir := range strings.Split(l, ",") {
+			if dir = strings.TrimSpace(dir); len(dir) != 0 {
+				locations = append(locations, dir)
+			}
+		}
+	}
+
+	return locations
+}
+
+// findConfigFile finds the yaml config file with given name in config search paths.
+func (c *AppEnv) findConfigFile(name string) (string, error) {
+	for _, dir := range c.ConfigSearchPaths() {
+		for _, ext := range []string{"yml", "yaml"} {
+			filename := filepath.Join(dir, fmt.Sprintf("%s.%s", name, ext))
+			if info, err := c.resourceFs.Stat(filename); err == nil && !info.IsDir() {
+				return filename, nil
+			}
+		}
+	}
+
+	return "", viper.ConfigFileNotFoundError{}
+}
+
+// readResource reads the file with name in config search paths in the same way as
+// config files, the last of which is the embedded resources.
+func (c *AppEnv) readResource(name string) ([]byte, error) {
+	if c.parent != nil {
+		return c.parent.readResource(name)
+	}
+
+	for _, dir := range c.ConfigSearchPaths() {
+		content, err := afero.ReadFile(c.resourceFs, filepath.Join(dir, name))
+		if err == nil {
+			return content, nil
+		}
+	}
 
-		case map[string]any:
-			cc, _ := c.expandMerge(v.(map[string]any), true)
-			newCfg[k] = cc
+	return nil, fs.ErrNotExist
+}
+
+// ConfigFiles returns all config files which have been merged into this environment.
+func (c *AppEnv) ConfigFiles() []string {
+	if c.parent != nil {
+		return c.parent.ConfigFiles()
+	}
 
-		default:
-			newCfg[k] = v
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]string(nil), c.configFiles...)
+}
+
+// expandMerge expands environment variables, resolves secret references and
+// merges config.
+func (c *AppEnv) expandMerge(cfg map[string]any, isChild bool,
+	resolvers []SecretResolver) (map[string]any, error) {
+	newCfg := make(map[string]any)
+
+	for k, v := range cfg {
+		value, err := c.expandValue(k, v, resolvers)
+		if err != nil {
+			return nil, err
 		}
+		newCfg[k] = value
 	}
 
 	if isChild {
 		return newCfg, nil
 	}
 
-	return nil, c.vp.MergeConfigMap(newCfg)
+	return nil, c.viper().MergeConfigMap(newCfg)
+}
+
+// expandValue expands and resolves the value of key, including the elements in slice.
+func (c *AppEnv) expandValue(key string, v any, resolvers []SecretResolver) (any, error) {
+	switch v := v.(type) {
+	case string:
+		value, err := resolveSecret(ExpandEnv(v), resolvers)
+		if err != nil {
+			return nil, fmt.Errorf("resolve config %s error: %v", key, err)
+		}
+		return value, nil
+
+	case map[string]any:
+		return c.expandMerge(v, true, resolvers)
+
+	case []any:
+		values := make([]any, 0, len(v))
+		for i, e := range v {
+			value, err := c.expandValue(fmt.Sprintf("%s[%d]", key, i), e, resolvers)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+
+	default:
+		return v, nil
+	}
 }
 
 // MergeConfigMap merges external configuration map into the configuration of application.
 func (c *AppEnv) MergeConfigMap(cfg map[string]any) error {
-	_, err := c.expandMerge(cfg, false)
+	if c.parent != nil {
+		return c.parent.MergeConfigMap(nestConfigMap(c.prefix, cfg))
+	}
+
+	_, err := c.expandMerge(cfg, false, retrieveSecretResolvers())
 	return err
 }
 
-// Unmarshal unmarshal configuration with `piper` tag.
+// AddDecodeHook adds a hook to convert values when unmarshalling configuration. The
+// hooks added will be applied before built-in hooks in the order they were added.
+func (c *AppEnv) AddDecodeHook(hook DecodeHook) {
+	if c.parent != nil {
+		c.parent.AddDecodeHook(hook)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.decodeHooks = append(c.decodeHooks, hook)
+}
+
+// Unmarshal unmarshal configuration with `piper` tag. Strings in configuration can
+// be decoded into durations, byte sizes, urls, ips, locations and the types which
+// implement encoding.TextUnmarshaler. Check AddDecodeHook to support other types.
 func (c *AppEnv) Unmarshal(prefix string, rawVal any) error {
-	return c.vp.UnmarshalKey(prefix, rawVal, func(c *mapstructure.DecoderConfig) {
-		c.TagName = piper
+	if c.parent != nil {
+		return c.parent.Unmarshal(c.key(prefix), rawVal)
+	}
+
+	c.mu.RLock()
+	hooks := append(append([]DecodeHook(nil), c.decodeHooks...), defaultDecodeHooks()...)
+	c.mu.RUnlock()
+
+	vp := c.viper()
+	input := overlayEnv(prefix, reflect.TypeOf(rawVal), vp.Get(prefix))
+	metadata := &mapstructure.Metadata{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:          piper,
+		Result:           rawVal,
+		Metadata:         metadata,
+		WeaklyTypedInput: true,
+		MatchName:        relaxedMatch,
+		DecodeHook:       composeDecodeHooks(hooks),
 	})
+	if err != nil {
+		return err
+	}
+	if err = decoder.Decode(input); err != nil {
+		return err
+	}
+
+	return c.checkUnknownKeys(prefix, metadata.Unused)
+}
+
+// checkUnknownKeys reports unknown keys under the prefix of bound property, it will
+// return error in strict binding mode, or log warning otherwise.
+func (c *AppEnv) checkUnknownKeys(prefix string, unused []string) error {
+	if len(unused) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(unused))
+	for _, k := range unused {
+		keys = append(keys, prefix+"."+k)
+	}
+	sort.Strings(keys)
+
+	if c.viper().GetBool(keyStrictBinding) {
+		return fmt.Errorf("unknown config keys: %s", strings.Join(keys, ", "))
+	}
+	slago.Logger().Warn().Strs("keys", keys).Msg("unknown config keys found, ignored")
+
+	return nil
+}
+
+// Bind unmarshals configuration with the given prefix into a new T and validates it
+// with `validate` tag rules if T is struct. This is useful to read a group of config
+// without wiring a ConfigProperty. For example:
+//
+//  db, err := piper.Bind[DbProperty](env, "db")
+func Bind[T any](env *AppEnv, prefix string) (T, error) {
+	var value T
+	if err := env.Unmarshal(prefix, &value); err != nil {
+		return value, err
+	}
+
+	if reflect.TypeOf(value) != nil && reflect.TypeOf(value).Kind() == reflect.Struct {
+		violations := validateProperty(env.key(prefix), &value)
+		if len(violations) != 0 {
+			return value, newPropertyValidationError(violations)
+		}
+	}
+
+	return value, nil
+}
+
+// Sub returns a scoped view of configuration with the given prefix. All the keys used
+// in the view are relative to the prefix, e.g. env.Sub("db").GetString("url") is the
+// same as env.GetString("db.url"). The view will see the changes of configuration.
+func (c *AppEnv) Sub(prefix string) *AppEnv {
+	root := c
+	if c.parent != nil {
+		root = c.parent
+	}
+
+	return &AppEnv{
+		cliName: c.cliName,
+		parent:  root,
+		prefix:  c.key(prefix),
+	}
+}
+
+// key returns the absolute key in configuration for the key relative to this view.
+func (c *AppEnv) key(key string) string {
+	if len(c.prefix) == 0 {
+		return key
+	}
+	if len(key) == 0 {
+		return c.prefix
+	}
+
+	return c.prefix + "." + key
+}
+
+// Keys returns all the keys in configuration in order. The keys of scoped view
+// are relative to its prefix.
+func (c *AppEnv) Keys() []string {
+	keys := make([]string, 0)
+	prefix := strings.ToLower(c.prefix)
+
+	for _, k := range c.viper().AllKeys() {
+		if len(prefix) == 0 {
+			keys = append(keys, k)
+		} else if strings.HasPrefix(k, prefix+".") {
+			keys = append(keys, strings.TrimPrefix(k, prefix+"."))
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// IsSet checks if the key has been set in configuration.
+func (c *AppEnv) IsSet(key string) bool {
+	return c.viper().IsSet(c.key(key))
+}
+
+// GetString gets the value of key as string.
+func (c *AppEnv) GetString(key string) string {
+	return c.viper().GetString(c.key(key))
+}
+
+// GetInt gets the value of key as int.
+func (c *AppEnv) GetInt(key string) int {
+	return c.viper().GetInt(c.key(key))
+}
+
+// GetBool gets the value of key as bool.
+func (c *AppEnv) GetBool(key string) bool {
+	return c.viper().GetBool(c.key(key))
+}
+
+// GetDuration gets the value of key as time.Duration, e.g. 30s.
+func (c *AppEnv) GetDuration(key string) time.Duration {
+	return c.viper().GetDuration(c.key(key))
+}
+
+// GetStringSlice gets the value of key as string slice.
+func (c *AppEnv) GetStringSlice(key string) []string {
+	return c.viper().GetStringSlice(c.key(key))
 }
 
 // Profile gets current active profile in command line if existed.
 func (c *AppEnv) Profile() string {
-	return c.vp.GetString(keyProfile)
+	return c.viper().GetString(keyProfile)
 }
 
 // ConfigName gets current config name for this application.
 func (c *AppEnv) ConfigName() string {
-	return c.vp.GetString(keyConfigName)
+	return c.viper().GetString(keyConfigName)
+}
+
+// nestConfigMap nests the config map under the given dot separated prefix.
+func nestConfigMap(prefix string, cfg map[string]any) map[string]any {
+	parts := strings.Split(prefix, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		cfg = map[string]any{parts[i]: cfg}
+	}
+
+	return cfg
 }
 
 // cmdName returns the command line name of the executed bin.
@@ -0,0 +1,116 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type envTestDbProperty struct {
+	Url  string `piper:"url" validate:"required"`
+	Pool int    `piper:"pool"`
+}
+
+func TestAppEnv(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "app env test")
+}
+
+var _ = Describe("app env", func() {
+	newTestEnv := func() *AppEnv {
+		env := newAppEnv()
+		Expect(env.MergeConfigMap(map[string]any{
+			"db": map[string]any{
+				"url":     "mysql://localhost",
+				"pool":    10,
+				"timeout": "3s",
+				"debug":   true,
+				"hosts":   []any{"a", "b"},
+			},
+		})).To(Succeed())
+		return env
+	}
+
+	It("typed getters", func() {
+		env := newTestEnv()
+		Expect(env.GetString("db.url")).To(Equal("mysql://localhost"))
+		Expect(env.GetInt("db.pool")).To(Equal(10))
+		Expect(env.GetBool("db.debug")).To(BeTrue())
+		Expect(env.GetDuration("db.timeout")).To(Equal(3 * time.Second))
+		Expect(env.GetStringSlice("db.hosts")).To(Equal([]string{"a", "b"}))
+		Expect(env.IsSet("db.url")).To(BeTrue())
+		Expect(env.IsSet("db.missing")).To(BeFalse())
+	})
+	It("sub view", func() {
+		env := newTestEnv()
+		db := env.Sub("db")
+		Expect(db.GetString("url")).To(Equal("mysql://localhost"))
+		Expect(db.Keys()).To(Equal([]string{"debug", "hosts", "pool", "timeout", "url"}))
+
+		Expect(db.MergeConfigMap(map[string]any{"pool": 20})).To(Succeed())
+		Expect(env.GetInt("db.pool")).To(Equal(20))
+		Expect(env.Sub("db").Sub("pool").key("")).To(Equal("db.pool"))
+	})
+	It("bind", func() {
+		env := newTestEnv()
+		db, err := Bind[envTestDbProperty](env, "db")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db).To(Equal(envTestDbProperty{Url: "mysql://localhost", Pool: 10}))
+
+		_, err = Bind[envTestDbProperty](env.Sub("db"), "missing")
+		Expect(err).To(MatchError("1 config property violation(s) found:" +
+			"\n\tdb.missing.url: is required"))
+	})
+	It("config search paths", func() {
+		_ = os.Setenv("PIPER_CONFIG_LOCATION", "/opt/a,/opt/b")
+		_ = os.Setenv("XDG_CONFIG_HOME", "/xdg")
+		defer os.Unsetenv("PIPER_CONFIG_LOCATION")
+		defer os.Unsetenv("XDG_CONFIG_HOME")
+
+		env := newAppEnv()
+		env.cliName = "demo"
+		env.configPaths = []string{"resources"}
+		env.viper().Set(keyConfigAdditionalLocation, []string{"/opt/extra"})
+		Expect(env.ConfigSearchPaths()).To(Equal([]string{
+			"/opt/a", "/opt/b", "resources", "/xdg/demo", "/etc/demo",
+			"/opt/extra", "/piper/resources",
+		}))
+
+		err := (&applicationConfigLoader{}).Load(env)
+		Expect(err).To(MatchError("no application.yml config file found in:" +
+			"\n\t/opt/a\n\t/opt/b\n\tresources\n\t/xdg/demo\n\t/etc/demo" +
+			"\n\t/opt/extra\n\t/piper/resources\n" +
+			"at least one config file should be presented"))
+	})
+	It("load config in embedded resources", func() {
+		env := newAppEnv()
+		env.configPaths = nil
+		env.viper().Set(keyConfigLocation, []string{"/nonexistent"})
+		env.viper().Set(keyConfigAdditionalLocation, []string{"/nonexistent"})
+		env.resourceFs = newResourceFs(fstest.MapFS{
+			"resources/application.yml": {Data: []byte("db:\n  pool: 3\n")},
+		})
+
+		Expect((&applicationConfigLoader{}).Load(env)).To(Succeed())
+		Expect(env.GetInt("db.pool")).To(Equal(3))
+		Expect(env.ConfigFiles()).To(Equal([]string{"/piper/resources/application.yml"}))
+	})
+})
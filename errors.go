@@ -15,57 +15,252 @@
 package piper
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"strings"
 )
 
-type noDepError struct {
-	key      providerKey
-	nodeName string
+var (
+	// ErrWiring is matched by errors.Is for all the errors caused by wiring, such as
+	// missing, ambiguous or cyclic dependencies.
+	ErrWiring = errors.New("wiring error")
+	// ErrConfig is matched by errors.Is for all the errors caused by configuration,
+	// such as config files not found or invalid config properties.
+	ErrConfig = errors.New("config error")
+)
+
+// NoDependencyError represents the error when no dependency found for a provider.
+type NoDependencyError struct {
+	// Key is the key of missing dependency.
+	Key ProviderKey
+	// Node is the name of provider which requires the dependency.
+	Node string
+	// Chain is the dependency chain from the root provider to Node.
+	Chain []string
 }
 
-func newNoDepError(key providerKey, nodeName string) error {
-	return &noDepError{
-		key:      key,
-		nodeName: nodeName,
+func newNoDependencyError(key ProviderKey, node *graphNode, chain []*graphNode) error {
+	return &NoDependencyError{
+		Key:   key,
+		Node:  node.name,
+		Chain: nodeNames(chain, node),
 	}
 }
 
-func (e *noDepError) Error() string {
-	return fmt.Sprintf("no dependency of %s found for %s", e.key, e.nodeName)
+func (e *NoDependencyError) Error() string {
+	return fmt.Sprintf("no dependency of %s found for %s", e.Key, e.Node)
+}
+
+func (e *NoDependencyError) Is(target error) bool {
+	return target == ErrWiring
 }
 
-type multiDepError struct {
-	key      providerKey
-	nodeName string
+// MultipleDependencyError represents the error when more than one dependencies found
+// for a provider, and none of them is primary.
+type MultipleDependencyError struct {
+	// Key is the key of ambiguous dependency.
+	Key ProviderKey
+	// Node is the name of provider which requires the dependency.
+	Node string
+	// Chain is the dependency chain from the root provider to Node.
+	Chain []string
+	// Candidates are the names of providers found for the dependency.
+	Candidates []string
 }
 
-func newMultiDepError(key providerKey, nodeName string) error {
-	return &multiDepError{
-		key:      key,
-		nodeName: nodeName,
+func newMultipleDependencyError(key ProviderKey, node *graphNode, chain []*graphNode,
+	candidates []*graphNode) error {
+	return &MultipleDependencyError{
+		Key:        key,
+		Node:       node.name,
+		Chain:      nodeNames(chain, node),
+		Candidates: nodeNames(candidates),
 	}
 }
 
-func (e *multiDepError) Error() string {
-	return fmt.Sprintf("more than one dependencies of %s found for %s", e.key, e.nodeName)
+func (e *MultipleDependencyError) Error() string {
+	return fmt.Sprintf("more than one dependencies of %s found for %s", e.Key, e.Node)
+}
+
+func (e *MultipleDependencyError) Is(target error) bool {
+	return target == ErrWiring
 }
 
-type defValueMismatchError struct {
-	defField, field *Field
-	nodeName        string
+// DefaultValueMismatchError represents the error when the default value in wire
+// option does not match the type of parameter.
+type DefaultValueMismatchError struct {
+	// DefaultValue is the field of default value.
+	DefaultValue *Field
+	// Field is the field of parameter.
+	Field *Field
+	// Node is the name of provider which requires the dependency.
+	Node string
+	// Chain is the dependency chain from the root provider to Node.
+	Chain []string
 }
 
-func newDefValueMismatchError(defField *Field, field *Field, nodeName string) error {
-	return &defValueMismatchError{
-		defField: defField,
-		field:    field,
-		nodeName: nodeName,
+func newDefaultValueMismatchError(defField *Field, field *Field, node *graphNode,
+	chain []*graphNode) error {
+	return &DefaultValueMismatchError{
+		DefaultValue: defField,
+		Field:        field,
+		Node:         node.name,
+		Chain:        nodeNames(chain, node),
 	}
 }
 
-func (e *defValueMismatchError) Error() string {
+func (e *DefaultValueMismatchError) Error() string {
 	return fmt.Sprintf("the default value %s\n\tis not match %s\n\tin %s",
-		e.defField, e.field, e.nodeName)
+		e.DefaultValue, e.Field, e.Node)
+}
+
+func (e *DefaultValueMismatchError) Is(target error) bool {
+	return target == ErrWiring
+}
+
+// RegistrationError represents the error when an invalid provider is wired.
+type RegistrationError struct {
+	// Provider is the name of invalid provider, or its type if the name is unknown.
+	Provider string
+	// Caller is the file and line where the provider was wired, e.g. main.go:12.
+	Caller string
+	// Err is the reason why the provider is invalid.
+	Err error
+}
+
+func newRegistrationError(provider string, caller string, err error) error {
+	return &RegistrationError{
+		Provider: provider,
+		Caller:   caller,
+		Err:      err,
+	}
+}
+
+func (e *RegistrationError) Error() string {
+	if len(e.Caller) == 0 {
+		return fmt.Sprintf("invalid provider %s: %v", e.Provider, e.Err)
+	}
+
+	return fmt.Sprintf("invalid provider %s wired at %s: %v", e.Provider, e.Caller, e.Err)
+}
+
+func (e *RegistrationError) Is(target error) bool {
+	return target == ErrWiring
+}
+
+func (e *RegistrationError) Unwrap() error {
+	return e.Err
+}
+
+// WiringErrors aggregates all the errors found when wiring and resolving dependencies,
+// so they can be fixed at once. Use errors.As to get any of the underlying errors.
+type WiringErrors struct {
+	// Errors are the errors in the order they were found.
+	Errors []error
+}
+
+// newWiringErrors returns the single error directly, otherwise aggregates them.
+func newWiringErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	return &WiringErrors{
+		Errors: errs,
+	}
+}
+
+func (e *WiringErrors) Error() string {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(fmt.Sprintf("%d wiring errors found:", len(e.Errors)))
+	for _, g := range e.Groups() {
+		buffer.WriteString("\n\t" + g.Provider + ":")
+		for _, err := range g.Errors {
+			buffer.WriteString("\n\t\t" + strings.ReplaceAll(err.Error(), "\n", "\n\t\t"))
+		}
+	}
+
+	return buffer.String()
+}
+
+func (e *WiringErrors) Is(target error) bool {
+	if target == ErrWiring {
+		return true
+	}
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *WiringErrors) As(target any) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WiringErrorGroup represents the wiring errors of the same provider.
+type WiringErrorGroup struct {
+	// Provider is the name of provider.
+	Provider string
+	// Errors are the errors of the provider.
+	Errors []error
+}
+
+// Groups groups the errors by provider in the order they were found.
+func (e *WiringErrors) Groups() []*WiringErrorGroup {
+	groups := make([]*WiringErrorGroup, 0)
+	indexes := make(map[string]int)
+	for _, err := range e.Errors {
+		provider := errorProvider(err)
+		index, ok := indexes[provider]
+		if !ok {
+			index = len(groups)
+			indexes[provider] = index
+			groups = append(groups, &WiringErrorGroup{Provider: provider})
+		}
+		groups[index].Errors = append(groups[index].Errors, err)
+	}
+
+	return groups
+}
+
+// errorProvider returns the name of provider which caused the wiring error.
+func errorProvider(err error) string {
+	switch e := err.(type) {
+	case *NoDependencyError:
+		return e.Node
+	case *MultipleDependencyError:
+		return e.Node
+	case *DefaultValueMismatchError:
+		return e.Node
+	case *CycleDependencyError:
+		if len(e.Chain) > 1 {
+			return e.Chain[len(e.Chain)-2]
+		}
+	case *RegistrationError:
+		return e.Provider
+	}
+
+	return "unknown"
+}
+
+// nodeNames returns the names of given nodes.
+func nodeNames(nodes []*graphNode, others ...*graphNode) []string {
+	names := make([]string, 0, len(nodes)+len(others))
+	for _, n := range append(append([]*graphNode(nil), nodes...), others...) {
+		names = append(names, n.name)
+	}
+
+	return names
 }
 
 type wireInError struct {
@@ -98,20 +293,104 @@ func (e *wireOutError) Error() string {
 		"option in provider: %s", e.providerName)
 }
 
-type appStartError struct {
+type importCycleError struct {
+	files []string
+}
+
+func newImportCycleError(files []string) error {
+	return &importCycleError{
+		files: files,
+	}
+}
+
+func (e *importCycleError) Is(target error) bool {
+	return target == ErrConfig
+}
+
+func (e *importCycleError) Error() string {
+	return "cycle config imports found: \n\t" + strings.Join(e.files, "\n\timports ")
+}
+
+// violation represents a problem found in configuration for the given key.
+type violation struct {
+	key string
+	msg string
+}
+
+type propertyValidationError struct {
+	violations []*violation
+}
+
+func newPropertyValidationError(violations []*violation) error {
+	return &propertyValidationError{
+		violations: violations,
+	}
+}
+
+func (e *propertyValidationError) Is(target error) bool {
+	return target == ErrConfig
+}
+
+func (e *propertyValidationError) Error() string {
+	errMsgBuffer := new(bytes.Buffer)
+	errMsgBuffer.WriteString(fmt.Sprintf("%d config property violation(s) found:", len(e.violations)))
+	for _, v := range e.violations {
+		errMsgBuffer.WriteString(fmt.Sprintf("\n\t%s: %s", v.key, v.msg))
+	}
+
+	return errMsgBuffer.String()
+}
+
+// configError wraps the error when loading configuration.
+type configError struct {
 	err error
 }
 
+func newConfigError(err error) error {
+	return &configError{
+		err: err,
+	}
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+func (e *configError) Is(target error) bool {
+	return target == ErrConfig
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+type appStartError struct {
+	err      error
+	analysis *FailureAnalysis
+}
+
 func newAppStartError(err error) *appStartError {
 	return &appStartError{
-		err: err,
+		err:      err,
+		analysis: analyzeFailure(err),
 	}
 }
 
 func (e *appStartError) Error() string {
-	return "\n" +
+	header := "\n" +
 		"********************************" + "\n" +
 		"*   application start failed   *" + "\n" +
-		"********************************" + "\n\n" +
-		e.err.Error()
+		"********************************" + "\n\n"
+	if e.analysis == nil {
+		return header + e.err.Error()
+	}
+
+	return header +
+		"Description:\n\n" + e.analysis.Description + "\n\n" +
+		"Action:\n\n" + e.analysis.Action
+}
+
+// Unwrap returns the error which caused application failed to start.
+func (e *appStartError) Unwrap() error {
+	return e.err
 }
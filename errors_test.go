@@ -0,0 +1,110 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestErrors(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "errors test")
+}
+
+var _ = Describe("errors", func() {
+	It("no dependency", func() {
+		tree := newDepTree()
+		tree.wire(newAnalyzerService)
+		err := newAppStartError(tree.resolveDependencies())
+
+		Expect(errors.Is(err, ErrWiring)).To(BeTrue())
+		Expect(errors.Is(err, ErrConfig)).To(BeFalse())
+		var e *NoDependencyError
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Key.Name()).To(HaveSuffix("piper.analyzerRepo"))
+		Expect(e.Key.IsPointer()).To(BeTrue())
+		Expect(e.Node).To(HaveSuffix("piper.newAnalyzerService"))
+	})
+	It("multiple dependencies", func() {
+		tree := newDepTree()
+		tree.wire(newAnalyzerRepoA, newAnalyzerRepoB, newAnalyzerService)
+		err := newAppStartError(tree.resolveDependencies())
+
+		Expect(errors.Is(err, ErrWiring)).To(BeTrue())
+		var e *MultipleDependencyError
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Candidates).To(HaveLen(2))
+	})
+	It("config error", func() {
+		err := newAppStartError(newConfigError(errors.New("config file not found")))
+
+		Expect(errors.Is(err, ErrConfig)).To(BeTrue())
+		Expect(errors.Is(err, ErrWiring)).To(BeFalse())
+	})
+	It("property validation error", func() {
+		err := newAppStartError(newConfigError(&propertyValidationError{
+			violations: []*violation{{key: "server.port", msg: "is required"}},
+		}))
+
+		var e *propertyValidationError
+		Expect(errors.Is(err, ErrConfig)).To(BeTrue())
+		Expect(errors.As(err, &e)).To(BeTrue())
+	})
+	It("aggregated wiring errors", func() {
+		tree := newDepTree()
+		tree.wire(newAnalyzerService, newAnalyzerValueService, newCycleA, newCycleB,
+			newCycleC, func() (*analyzerRepo, error) { return nil, nil })
+		err := tree.resolveDependencies()
+
+		var e *WiringErrors
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(errors.Is(err, ErrWiring)).To(BeTrue())
+		Expect(e.Errors).To(HaveLen(4))
+		Expect(e.Errors[0]).To(BeAssignableToTypeOf(&RegistrationError{}))
+		Expect(e.Groups()).To(HaveLen(4))
+
+		var cycleErr *CycleDependencyError
+		Expect(errors.As(err, &cycleErr)).To(BeTrue())
+		Expect(cycleErr.Chain).To(HaveLen(4))
+
+		analysis := (&wiringErrorsFailureAnalyzer{}).Analyze(err)
+		Expect(analysis).NotTo(BeNil())
+		Expect(analysis.Description).To(HavePrefix("4 wiring errors found:"))
+		Expect(analysis.Description).To(ContainSubstring(
+			"piper.newAnalyzerValueService:\n\n3. piper.newAnalyzerValueService requires"))
+	})
+	It("registration error", func() {
+		tree := newDepTree()
+		Expect(func() {
+			tree.wire(analyzerRepo{}, nil)
+			tree.wireWithOption(newAnalyzerRepoA, Name("repo"))
+		}).NotTo(Panic())
+
+		err := tree.resolveDependencies()
+		var e *WiringErrors
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Errors).To(HaveLen(3))
+		for _, err := range e.Errors {
+			var regErr *RegistrationError
+			Expect(errors.As(err, &regErr)).To(BeTrue())
+			Expect(regErr.Caller).To(ContainSubstring("errors_test.go:"))
+		}
+		Expect(e.Errors[0].Error()).To(ContainSubstring("only non nil pointer type"))
+	})
+})
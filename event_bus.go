@@ -0,0 +1,199 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/coolerfall/slago"
+)
+
+// Listener receives the events of type E. All the wired providers implementing
+// Listener[E] will receive the events published with Publish[E] or PublishAsync[E],
+// in the order of Ordered if implemented.
+type Listener[E any] interface {
+	// OnEvent will be invoked when an event of type E published.
+	OnEvent(event E) error
+}
+
+// ListenerError represents the error returned or panicked by a listener.
+type ListenerError struct {
+	// Listener is the type of listener.
+	Listener string
+	// Err is the error returned or recovered from listener.
+	Err error
+}
+
+func (e *ListenerError) Error() string {
+	return fmt.Sprintf("listener %s error: %v", e.Listener, e.Err)
+}
+
+func (e *ListenerError) Unwrap() error {
+	return e.Err
+}
+
+// PublishError aggregates the errors of listeners which failed to handle the event.
+// The failure of one listener does not stop the others receiving the event.
+type PublishError struct {
+	// Errors are the errors in the order of listeners.
+	Errors []*ListenerError
+}
+
+func (e *PublishError) Error() string {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(fmt.Sprintf("%d listeners failed:", len(e.Errors)))
+	for _, err := range e.Errors {
+		buffer.WriteString("\n\t" + strings.ReplaceAll(err.Error(), "\n", "\n\t"))
+	}
+
+	return buffer.String()
+}
+
+func (e *PublishError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *PublishError) As(target any) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Publish delivers the event to all the listeners of E synchronously, and returns
+// *PublishError if any of them returned error or panicked. The listeners will be
+// instantiated on first publishing, so it should be called after dependencies resolved.
+func Publish[E any](event E) error {
+	return publish(_depTree(), event)
+}
+
+// PublishAsync delivers the event to all the listeners of E in another goroutine, so
+// the publisher will not be blocked. The events published with PublishAsync are
+// delivered one by one in the order they were published, and the errors will be logged.
+func PublishAsync[E any](event E) {
+	publishAsync(_depTree(), event)
+}
+
+func publish[E any](tree *depTree, event E) error {
+	return publishTo(tree.retrieve(reflect.TypeOf((*Listener[E])(nil))), event)
+}
+
+// publishInstances delivers the event only to the listeners which have been
+// instantiated, so no listener will be instantiated before properties bound. The
+// error will be logged.
+func publishInstances[E any](tree *depTree, event E) {
+	err := publishTo(tree.retrieveInstances(reflect.TypeOf((*Listener[E])(nil))), event)
+	if err != nil {
+		slago.Logger().Error().Err(err).Msgf("publish %T error", event)
+	}
+}
+
+func publishAsync[E any](tree *depTree, event E) {
+	_asyncEvents.push(func() {
+		publishAndLog(tree, event)
+	})
+}
+
+func publishTo[E any](listeners []any, event E) error {
+	errs := make([]*ListenerError, 0)
+	for _, v := range listeners {
+		if err := deliver(v.(Listener[E]), event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &PublishError{
+		Errors: errs,
+	}
+}
+
+// publishAndLog publishes the event synchronously, and logs the error.
+func publishAndLog[E any](tree *depTree, event E) {
+	if err := publish(tree, event); err != nil {
+		slago.Logger().Error().Err(err).Msgf("publish %T error", event)
+	}
+}
+
+// deliver delivers the event to listener, and recovers the panic as error.
+func deliver[E any](l Listener[E], event E) (err *ListenerError) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				e = fmt.Errorf("%v", r)
+			}
+			err = &ListenerError{Listener: fmt.Sprintf("%T", l), Err: e}
+		}
+	}()
+	if e := l.OnEvent(event); e != nil {
+		return &ListenerError{Listener: fmt.Sprintf("%T", l), Err: e}
+	}
+
+	return nil
+}
+
+var _asyncEvents = &eventQueue{}
+
+// eventQueue runs the tasks one by one in a goroutine in the order they were pushed.
+// The goroutine exits when the queue is empty, and starts again on next push.
+type eventQueue struct {
+	mu      sync.Mutex
+	tasks   []func()
+	running bool
+}
+
+func (q *eventQueue) push(task func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.tasks = append(q.tasks, task)
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *eventQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.tasks) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		task := q.tasks[0]
+		q.tasks = q.tasks[1:]
+		q.mu.Unlock()
+
+		task()
+	}
+}
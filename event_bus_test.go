@@ -0,0 +1,173 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type orderEvent struct {
+	mu       sync.Mutex
+	received []string
+	done     chan struct{}
+}
+
+func (e *orderEvent) receive(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.received = append(e.received, name)
+}
+
+type firstListener struct {
+}
+
+func (*firstListener) Order() int {
+	return HighestOrder
+}
+
+func (*firstListener) OnEvent(event *orderEvent) error {
+	event.receive("first")
+	return errors.New("first failed")
+}
+
+type panicListener struct {
+}
+
+func (*panicListener) Order() int {
+	return 1
+}
+
+func (*panicListener) OnEvent(event *orderEvent) error {
+	event.receive("panic")
+	panic("listener panicked")
+}
+
+type lastListener struct {
+}
+
+func (*lastListener) OnEvent(event *orderEvent) error {
+	event.receive("last")
+	if event.done != nil {
+		close(event.done)
+	}
+	return nil
+}
+
+type otherEventListener struct {
+}
+
+func (*otherEventListener) OnEvent(_ *ConfigChangeEvent) error {
+	return errors.New("should not receive order event")
+}
+
+type seqEvent struct {
+	seq int
+}
+
+type seqListener struct {
+	mu       sync.Mutex
+	received []int
+}
+
+func (l *seqListener) OnEvent(event *seqEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.received = append(l.received, event.seq)
+	return nil
+}
+
+func (l *seqListener) seqs() []int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]int(nil), l.received...)
+}
+
+func TestEventBus(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "event bus test")
+}
+
+var _ = Describe("event bus", func() {
+	var tree *depTree
+
+	BeforeEach(func() {
+		tree = newDepTree()
+		tree.wire(&lastListener{}, &panicListener{}, &firstListener{}, &otherEventListener{})
+		Expect(tree.resolveDependencies()).To(Succeed())
+	})
+
+	It("deliver in order and isolate errors", func() {
+		event := &orderEvent{}
+		err := publish(tree, event)
+		Expect(event.received).To(Equal([]string{"first", "panic", "last"}))
+
+		var e *PublishError
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Errors).To(HaveLen(2))
+		Expect(e.Errors[0].Listener).To(Equal("*piper.firstListener"))
+		Expect(e.Errors[0].Err).To(MatchError("first failed"))
+		Expect(e.Errors[1].Listener).To(Equal("*piper.panicListener"))
+		Expect(e.Errors[1].Err).To(MatchError("listener panicked"))
+	})
+	It("deliver asynchronously", func() {
+		event := &orderEvent{done: make(chan struct{})}
+		published := make(chan struct{})
+		go func() {
+			defer close(published)
+			publishAndLog(tree, event)
+		}()
+		Eventually(event.done).Should(BeClosed())
+		// wait for the errors logged, so logging will not be raced with other tests
+		Eventually(published).Should(BeClosed())
+		event.mu.Lock()
+		defer event.mu.Unlock()
+		Expect(event.received).To(Equal([]string{"first", "panic", "last"}))
+	})
+	It("deliver asynchronous events in order", func() {
+		listener := &seqListener{}
+		tree := newDepTree()
+		tree.wire(listener)
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		expected := make([]int, 0)
+		for i := 0; i < 100; i++ {
+			publishAsync(tree, &seqEvent{seq: i})
+			expected = append(expected, i)
+		}
+		Eventually(listener.seqs).Should(Equal(expected))
+	})
+	It("deliver to instantiated listeners only", func() {
+		listener := &seqListener{}
+		var created bool
+		tree := newDepTree()
+		tree.wire(listener, func() *seqListener {
+			created = true
+			return &seqListener{}
+		})
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		publishInstances(tree, &seqEvent{seq: 1})
+		Expect(created).To(BeFalse())
+		Expect(listener.seqs()).To(Equal([]int{1}))
+	})
+	It("no listener", func() {
+		Expect(publish(tree, &GraphResolvedEvent{})).To(Succeed())
+	})
+})
@@ -0,0 +1,276 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FailureAnalysis represents an actionable report for a failure when starting.
+type FailureAnalysis struct {
+	// Description describes what went wrong.
+	Description string
+	// Action describes how to fix the failure.
+	Action string
+}
+
+// FailureAnalyzer analyzes the error when application failed to start, and turns it
+// into an actionable report. The analyzers will be tried in order until one of them
+// returns an analysis. Note that analyzers are retrieved when dependencies may not be
+// resolved, so they should be wired as instances instead of constructors.
+type FailureAnalyzer interface {
+	// Analyze analyzes the error, and returns nil if the error cannot be analyzed.
+	Analyze(err error) *FailureAnalysis
+}
+
+func init() {
+	tree := _depTree()
+	Wire(&wiringErrorsFailureAnalyzer{}, &noDepFailureAnalyzer{tree: tree},
+		&multiDepFailureAnalyzer{}, &cycleDepFailureAnalyzer{})
+}
+
+// analyzeFailure analyzes error with all wired failure analyzers.
+func analyzeFailure(err error) *FailureAnalysis {
+	for _, v := range _depTree().retrieveInstances(reflect.TypeOf((*FailureAnalyzer)(nil))) {
+		if analysis := v.(FailureAnalyzer).Analyze(err); analysis != nil {
+			return analysis
+		}
+	}
+
+	return nil
+}
+
+// shortName strips the package path of the qualified name, e.g. github.com/acme/db.Pool
+// will be db.Pool.
+func shortName(name string) string {
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		return name[index+1:]
+	}
+
+	return name
+}
+
+// typeName returns the short name of type in provider key, e.g. *db.Pool.
+func typeName(key ProviderKey) string {
+	name := shortName(key.name)
+	if key.isPointer {
+		name = "*" + name
+	}
+	if len(key.alias) != 0 {
+		name = fmt.Sprintf("%s named %q", name, key.alias)
+	}
+
+	return name
+}
+
+// wiringErrorsFailureAnalyzer analyzes each of the aggregated wiring errors, and
+// reports them grouped by provider.
+type wiringErrorsFailureAnalyzer struct {
+}
+
+func (*wiringErrorsFailureAnalyzer) Order() int {
+	// run before the others, which only analyze the first matched error
+	return HighestOrder
+}
+
+func (*wiringErrorsFailureAnalyzer) Analyze(err error) *FailureAnalysis {
+	var e *WiringErrors
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	descBuffer := new(bytes.Buffer)
+	actionBuffer := new(bytes.Buffer)
+	descBuffer.WriteString(fmt.Sprintf("%d wiring errors found:", len(e.Errors)))
+	index := 0
+	for _, g := range e.Groups() {
+		descBuffer.WriteString("\n\n" + shortName(g.Provider) + ":")
+		for _, err := range g.Errors {
+			index++
+			description, action := err.Error(), ""
+			if analysis := analyzeFailure(err); analysis != nil {
+				description, action = analysis.Description, analysis.Action
+			}
+			descBuffer.WriteString(fmt.Sprintf("\n\n%d. %s", index,
+				strings.ReplaceAll(description, "\n", "\n   ")))
+			if len(action) != 0 {
+				actionBuffer.WriteString(fmt.Sprintf("%d. %s\n\n", index,
+					strings.ReplaceAll(action, "\n", "\n   ")))
+			}
+		}
+	}
+
+	return &FailureAnalysis{
+		Description: descBuffer.String(),
+		Action:      strings.TrimSpace(actionBuffer.String()),
+	}
+}
+
+// noDepFailureAnalyzer suggests the close matches of missing dependency.
+type noDepFailureAnalyzer struct {
+	tree *depTree
+}
+
+func (*noDepFailureAnalyzer) Order() int {
+	return LowestOrder
+}
+
+func (a *noDepFailureAnalyzer) Analyze(err error) *FailureAnalysis {
+	var e *NoDependencyError
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	tree := a.tree
+	description := fmt.Sprintf("%s requires %s, but no provider of it was wired.",
+		shortName(e.Node), typeName(e.Key))
+
+	// the provider exists, but not active in current profile
+	if nodes := tree.providers[e.Key]; len(nodes) != 0 {
+		profiles := make([]string, 0)
+		for _, n := range nodes {
+			_, outOpt := tree.splitOptions(tree.options[n.id])
+			if outOpt != nil {
+				profiles = append(profiles, outOpt.profiles...)
+			}
+		}
+		return &FailureAnalysis{
+			Description: fmt.Sprintf("%s requires %s, but its provider is not active "+
+				"in profile %q.", shortName(e.Node), typeName(e.Key), tree.profile),
+			Action: fmt.Sprintf("Consider starting with one of the profiles %v, "+
+				"or wiring a provider for profile %q.", profiles, tree.profile),
+		}
+	}
+
+	suggestions := make([]string, 0)
+	typeShortName := e.Key.name[strings.LastIndex(e.Key.name, ".")+1:]
+	for key, nodes := range tree.providers {
+		var reason string
+		switch {
+		case key.name == e.Key.name && key.alias == e.Key.alias:
+			if key.isPointer {
+				reason = "is pointer, consider requiring pointer type"
+			} else {
+				reason = "is not pointer, consider requiring value type"
+			}
+		case key.name == e.Key.name && key.isPointer == e.Key.isPointer:
+			if len(e.Key.alias) == 0 {
+				reason = fmt.Sprintf("is named, consider using piper.Name(%q)", key.alias)
+			} else if len(key.alias) == 0 {
+				reason = "has no name, consider removing the name option"
+			} else {
+				reason = fmt.Sprintf("has a different name, consider using piper.Name(%q)",
+					key.alias)
+			}
+		case strings.HasSuffix(key.name, "."+typeShortName) && key.name != e.Key.name:
+			reason = "is the same type name in a different package: " + key.name
+		default:
+			continue
+		}
+
+		for _, n := range nodes {
+			suggestions = append(suggestions, fmt.Sprintf("%s provided by %s %s",
+				typeName(key), shortName(n.name), reason))
+		}
+	}
+	sort.Strings(suggestions)
+
+	if len(suggestions) == 0 {
+		return &FailureAnalysis{
+			Description: description,
+			Action: fmt.Sprintf("Consider wiring a provider of %s with piper.Wire.",
+				typeName(e.Key)),
+		}
+	}
+
+	return &FailureAnalysis{
+		Description: description,
+		Action: "Consider the following close matches:\n\t" +
+			strings.Join(suggestions, "\n\t"),
+	}
+}
+
+// multiDepFailureAnalyzer lists all the candidates when multiple providers found.
+type multiDepFailureAnalyzer struct {
+}
+
+func (*multiDepFailureAnalyzer) Order() int {
+	return LowestOrder
+}
+
+func (*multiDepFailureAnalyzer) Analyze(err error) *FailureAnalysis {
+	var e *MultipleDependencyError
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	buffer := new(bytes.Buffer)
+	buffer.WriteString(fmt.Sprintf("%s requires a single %s, but %d providers were found:",
+		shortName(e.Node), typeName(e.Key), len(e.Candidates)))
+	for _, name := range e.Candidates {
+		buffer.WriteString("\n\t" + shortName(name))
+	}
+
+	return &FailureAnalysis{
+		Description: buffer.String(),
+		Action: "Consider marking one of the providers with piper.Primary(), " +
+			"or naming them with piper.OutName and requiring one with piper.Name, " +
+			fmt.Sprintf("or requiring []%s to receive all of them.", typeName(e.Key)),
+	}
+}
+
+// cycleDepFailureAnalyzer points out the edge which can be broken in the cycle.
+type cycleDepFailureAnalyzer struct {
+}
+
+func (*cycleDepFailureAnalyzer) Order() int {
+	return LowestOrder
+}
+
+func (*cycleDepFailureAnalyzer) Analyze(err error) *FailureAnalysis {
+	var e *CycleDependencyError
+	if !errors.As(err, &e) || len(e.Chain) < 2 {
+		return nil
+	}
+
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("The dependencies of providers form a cycle:\n")
+	for i, name := range e.Chain {
+		if i == 0 {
+			buffer.WriteString("\t┌─> " + shortName(name))
+		} else if i == len(e.Chain)-1 {
+			buffer.WriteString("\n\t└── " + shortName(name))
+		} else {
+			buffer.WriteString("\n\t│   " + shortName(name))
+		}
+	}
+
+	// the edge closing the cycle is usually the one added last
+	from := e.Chain[len(e.Chain)-2]
+	to := e.Chain[len(e.Chain)-1]
+
+	return &FailureAnalysis{
+		Description: buffer.String(),
+		Action: fmt.Sprintf("Consider breaking the cycle at %s -> %s: remove the "+
+			"parameter from %s and retrieve it lazily with piper.Retrieve after the "+
+			"application started, e.g. in StartListener, or move the shared part of "+
+			"them into a new provider.", shortName(from), shortName(to), shortName(from)),
+	}
+}
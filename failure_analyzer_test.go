@@ -0,0 +1,89 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type analyzerRepo struct {
+}
+
+type analyzerService struct {
+}
+
+func newAnalyzerRepoA() *analyzerRepo {
+	return &analyzerRepo{}
+}
+
+func newAnalyzerRepoB() *analyzerRepo {
+	return &analyzerRepo{}
+}
+
+func newAnalyzerService(_ *analyzerRepo) *analyzerService {
+	return &analyzerService{}
+}
+
+func newAnalyzerValueService(_ analyzerRepo) *analyzerService {
+	return &analyzerService{}
+}
+
+func TestFailureAnalyzer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "failure analyzer test")
+}
+
+var _ = Describe("failure analyzer", func() {
+	It("pointer mismatch", func() {
+		tree := newDepTree()
+		tree.wire(newAnalyzerRepoA, newAnalyzerValueService)
+		err := tree.resolveDependencies()
+		Expect(err).To(HaveOccurred())
+
+		analysis := (&noDepFailureAnalyzer{tree: tree}).Analyze(err)
+		Expect(analysis).NotTo(BeNil())
+		Expect(analysis.Description).To(Equal("piper.newAnalyzerValueService requires " +
+			"piper.analyzerRepo, but no provider of it was wired."))
+		Expect(analysis.Action).To(Equal("Consider the following close matches:\n\t" +
+			"*piper.analyzerRepo provided by piper.newAnalyzerRepoA is pointer, " +
+			"consider requiring pointer type"))
+	})
+	It("multiple candidates", func() {
+		tree := newDepTree()
+		tree.wire(newAnalyzerRepoA, newAnalyzerRepoB, newAnalyzerService)
+		err := tree.resolveDependencies()
+		Expect(err).To(HaveOccurred())
+		Expect((&noDepFailureAnalyzer{tree: tree}).Analyze(err)).To(BeNil())
+
+		analysis := (&multiDepFailureAnalyzer{}).Analyze(err)
+		Expect(analysis).NotTo(BeNil())
+		Expect(analysis.Description).To(Equal("piper.newAnalyzerService requires a " +
+			"single *piper.analyzerRepo, but 2 providers were found:" +
+			"\n\tpiper.newAnalyzerRepoA\n\tpiper.newAnalyzerRepoB"))
+	})
+	It("cycle", func() {
+		tree := newDepTree()
+		tree.wire(newCycleA, newCycleB, newCycleC)
+		err := tree.resolveDependencies()
+		Expect(err).To(HaveOccurred())
+
+		analysis := (&cycleDepFailureAnalyzer{}).Analyze(err)
+		Expect(analysis).NotTo(BeNil())
+		Expect(analysis.Action).To(ContainSubstring("piper.newCycle"))
+	})
+})
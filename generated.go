@@ -0,0 +1,305 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+
+	"github.com/coolerfall/slago"
+)
+
+// GeneratedProvider constructs the provider with the resolved arguments without
+// reflection. It is generated by `piper gen`, and should not be written by hand.
+type GeneratedProvider func(args []any) any
+
+// GeneratedGraph is the dependency graph resolved by `piper gen` for a profile. The
+// container uses it instead of resolving dependencies at runtime, and instantiates
+// the nodes in order after properties bound. It is generated by `piper gen`, and
+// should not be written by hand.
+type GeneratedGraph struct {
+	// Profile is the profile which the graph was resolved for.
+	Profile string
+	// Nodes are all the providers active in the profile, in dependency order.
+	Nodes []GeneratedNode
+}
+
+// GeneratedNode is a provider in GeneratedGraph.
+type GeneratedNode struct {
+	// Name is the actual name of provider, e.g. github.com/acme/app.newService. It is
+	// empty if the provider cannot be named, such as a func literal.
+	Name string
+	// Caller is the file and line where the provider was wired, e.g. app.go:42.
+	Caller string
+	// Args are the indexes of nodes for each in parameter, all the nodes of it if the
+	// parameter is a collection, or nil if the default value is used.
+	Args [][]int
+}
+
+// UseGenerated registers the providers generated by `piper gen`, keyed by the actual
+// name of provider func, e.g. github.com/acme/app.newService. The generated providers
+// will be called instead of reflection when instantiating. The providers not generated
+// fall back to reflection, such as func literals.
+func UseGenerated(providers map[string]GeneratedProvider) {
+	tree := _depTree()
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	for name, p := range providers {
+		tree.generated[name] = p
+	}
+}
+
+// UseGeneratedGraph registers the graphs generated by `piper gen` for each profile. If
+// the graph of active profile still matches the providers wired, the dependencies will
+// not be resolved at runtime, and all the providers are instantiated in the order of
+// graph when starting. Otherwise the container falls back to resolving at runtime, so
+// the stale generated code never breaks the application.
+func UseGeneratedGraph(graphs ...*GeneratedGraph) {
+	tree := _depTree()
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	for _, g := range graphs {
+		tree.graphs[g.Profile] = g
+	}
+}
+
+// GeneratedArg returns the argument at index as T, it returns the zero value of T if
+// the argument is nil. This is used by the code generated by `piper gen`.
+func GeneratedArg[T any](args []any, index int) T {
+	if args[index] == nil {
+		var zero T
+		return zero
+	}
+
+	return args[index].(T)
+}
+
+// GeneratedSlice returns the collection argument at index as []T, the elements which
+// are provided as []T will be flattened. The default value of collection is returned
+// as it is. This is used by the code generated by `piper gen`.
+func GeneratedSlice[T any](args []any, index int) []T {
+	switch arg := args[index].(type) {
+	case []T:
+		return arg
+	case []any:
+		var result []T
+		for i := range arg {
+			if s, ok := arg[i].([]T); ok {
+				result = append(result, s...)
+			} else {
+				result = append(result, GeneratedArg[T](arg, i))
+			}
+		}
+		return result
+	}
+
+	return nil
+}
+
+// useGraph resolves the dependencies with the generated graph of current profile. It
+// returns false if no graph was generated for the profile, or the graph does not match
+// the providers wired. It should be called with lock held.
+func (c *depTree) useGraph() bool {
+	graph, ok := c.graphs[c.profile]
+	if !ok {
+		return false
+	}
+
+	nodes, err := c.matchGraph(graph)
+	if err != nil {
+		slago.Logger().Warn().Err(err).Msg("generated graph is stale, resolve " +
+			"dependencies at runtime instead, run piper gen to regenerate")
+		return false
+	}
+
+	for i, gn := range graph.Nodes {
+		node := nodes[i]
+		if node.ctorType == nil {
+			continue
+		}
+
+		inOpts, _ := c.splitOptions(c.options[node.id])
+		for j, arg := range gn.Args {
+			inType := node.ctorType.In(j)
+			switch {
+			case arg == nil:
+				node.dependencies = append(node.dependencies, &graphNode{
+					name:     inType.String(),
+					resolved: true,
+					state:    1,
+					provided: c.defaultOptValue(inOpts[j]),
+				})
+			case isCollectionParam(inType):
+				collectionNode := &graphNode{
+					ctorType:     inType,
+					resolved:     true,
+					isCollection: true,
+				}
+				for _, index := range arg {
+					collectionNode.dependencies = append(collectionNode.dependencies,
+						nodes[index])
+				}
+				node.dependencies = append(node.dependencies, collectionNode)
+			default:
+				node.dependencies = append(node.dependencies, nodes[arg[0]])
+			}
+		}
+		node.resolved = true
+	}
+	c.graphOrder = nodes
+
+	return true
+}
+
+// matchGraph finds the wired node for each node in graph by name and the location
+// where it was wired, and checks if the dependencies in graph can still be provided
+// to them. It returns the nodes in the order of graph.
+func (c *depTree) matchGraph(graph *GeneratedGraph) ([]*graphNode, error) {
+	// the active nodes grouped by the file and line where they were wired
+	callers := make(map[string][]*graphNode)
+	count := 0
+	for _, nodes := range c.providers {
+		for _, n := range nodes {
+			if c.active(n) {
+				caller := filepath.Base(n.caller)
+				callers[caller] = append(callers[caller], n)
+				count++
+			}
+		}
+	}
+	if count != len(graph.Nodes) {
+		return nil, fmt.Errorf("%d providers wired, but %d generated", count,
+			len(graph.Nodes))
+	}
+
+	// match the named nodes first, then the only one left in the same location
+	nodes := make([]*graphNode, len(graph.Nodes))
+	matched := make(map[*graphNode]bool)
+	for _, named := range []bool{true, false} {
+		for i, gn := range graph.Nodes {
+			if (len(gn.Name) != 0) != named {
+				continue
+			}
+			for _, n := range callers[gn.Caller] {
+				if matched[n] || named && n.name != gn.Name {
+					continue
+				}
+				if nodes[i] != nil {
+					return nil, fmt.Errorf("more than one provider wired in %s", gn.Caller)
+				}
+				nodes[i] = n
+				if named {
+					break
+				}
+			}
+			if nodes[i] == nil {
+				return nil, fmt.Errorf("no provider %s wired in %s", gn.Name, gn.Caller)
+			}
+			matched[nodes[i]] = true
+		}
+	}
+
+	for i, gn := range graph.Nodes {
+		if err := c.matchArgs(nodes, i, gn.Args); err != nil {
+			return nil, err
+		}
+	}
+
+	return nodes, nil
+}
+
+// matchArgs checks if the arguments in graph can be provided to the node at index.
+func (c *depTree) matchArgs(nodes []*graphNode, index int, args [][]int) error {
+	node := nodes[index]
+	if node.ctorType == nil {
+		if len(args) != 0 {
+			return fmt.Errorf("%s is not a func provider", node.name)
+		}
+		return nil
+	}
+	if len(args) != node.ctorType.NumIn() {
+		return fmt.Errorf("the parameters of %s changed", node.name)
+	}
+
+	inOpts, _ := c.splitOptions(c.options[node.id])
+	for i, arg := range args {
+		inType := node.ctorType.In(i)
+		if arg == nil {
+			if i >= len(inOpts) || c.defaultOptValue(inOpts[i]) == nil {
+				return fmt.Errorf("no default value of parameter %d of %s", i, node.name)
+			}
+			continue
+		}
+
+		collection := isCollectionParam(inType)
+		if !collection && len(arg) != 1 {
+			return fmt.Errorf("parameter %d of %s should have one dependency", i,
+				node.name)
+		}
+		for _, depIndex := range arg {
+			if depIndex < 0 || depIndex >= index {
+				return fmt.Errorf("the dependencies of %s are not in order", node.name)
+			}
+			if !canProvide(nodes[depIndex], inType, collection) {
+				return fmt.Errorf("%s cannot be provided to parameter %d of %s",
+					nodes[depIndex].name, i, node.name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// instantiateGraph instantiates all the nodes of generated graph in order, it does
+// nothing if the graph was not used.
+func (c *depTree) instantiateGraph() error {
+	id := goroutineId()
+	for _, node := range c.graphOrder {
+		if err := c.safeBuild(id, node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isCollectionParam checks if all the providers should be collected for the in
+// parameter, in the same way as resolving.
+func isCollectionParam(inType reflect.Type) bool {
+	kind := inType.Kind()
+	if kind == reflect.Ptr {
+		kind = inType.Elem().Kind()
+	}
+
+	return kind == reflect.Slice
+}
+
+// canProvide checks if the value provided by node can be passed as the in parameter,
+// or be an element of it if the parameter is a collection.
+func canProvide(node *graphNode, inType reflect.Type, collection bool) bool {
+	var outType reflect.Type
+	if node.ctorType != nil {
+		outType = node.ctorType.Out(0)
+	} else {
+		outType = reflect.TypeOf(node.provided)
+	}
+
+	if collection && inType.Kind() == reflect.Slice {
+		return outType.AssignableTo(inType) || outType.AssignableTo(inType.Elem())
+	}
+
+	return outType.AssignableTo(inType)
+}
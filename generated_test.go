@@ -0,0 +1,104 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGenerated(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "generated test")
+}
+
+var _ = Describe("generated", func() {
+	It("instantiate with generated provider", func() {
+		tree := newDepTree()
+		var repo *analyzerRepo
+		tree.generated["github.com/go-piper/piper.newAnalyzerService"] = func(args []any) any {
+			repo = GeneratedArg[*analyzerRepo](args, 0)
+			return newAnalyzerService(repo)
+		}
+		tree.wire(newAnalyzerRepoA, newAnalyzerService)
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		services := tree.retrieve(reflect.TypeOf((*analyzerService)(nil)))
+		Expect(services).To(HaveLen(1))
+		Expect(repo).NotTo(BeNil())
+	})
+	It("instantiate generated graph in order", func() {
+		newGraph := func(caller string, serviceArgs [][]int) *GeneratedGraph {
+			return &GeneratedGraph{Nodes: []GeneratedNode{
+				{Name: "github.com/go-piper/piper.newAnalyzerRepoA", Caller: caller},
+				{Name: "github.com/go-piper/piper.newAnalyzerService", Caller: caller,
+					Args: serviceArgs},
+			}}
+		}
+		newTree := func(graph func(caller string) *GeneratedGraph) *depTree {
+			tree := newDepTree()
+			tree.wire(newAnalyzerRepoA, newAnalyzerService)
+			caller := filepath.Base(tree.unresolvedNodes[0].caller)
+			tree.graphs[""] = graph(caller)
+			Expect(tree.resolveDependencies()).To(Succeed())
+			return tree
+		}
+
+		tree := newTree(func(caller string) *GeneratedGraph {
+			return newGraph(caller, [][]int{{0}})
+		})
+		Expect(tree.graphOrder).To(HaveLen(2))
+		Expect(tree.instantiateGraph()).To(Succeed())
+		for _, node := range tree.graphOrder {
+			Expect(node.isInstantiated()).To(BeTrue())
+		}
+		Expect(tree.graphOrder[1].dependencies).To(Equal([]*graphNode{tree.graphOrder[0]}))
+
+		// fall back to resolving at runtime if the graph is stale
+		for _, graph := range []func(caller string) *GeneratedGraph{
+			func(caller string) *GeneratedGraph {
+				return newGraph("moved.go:1", [][]int{{0}})
+			},
+			func(caller string) *GeneratedGraph {
+				return newGraph(caller, [][]int{{1}})
+			},
+			func(caller string) *GeneratedGraph {
+				return newGraph(caller, nil)
+			},
+			func(caller string) *GeneratedGraph {
+				return &GeneratedGraph{Nodes: newGraph(caller, [][]int{{0}}).Nodes[:1]}
+			},
+		} {
+			tree = newTree(graph)
+			Expect(tree.graphOrder).To(BeEmpty())
+			Expect(tree.instantiateGraph()).To(Succeed())
+			services := tree.retrieve(reflect.TypeOf((*analyzerService)(nil)))
+			Expect(services).To(HaveLen(1))
+		}
+	})
+	It("generated slice", func() {
+		elems := []any{[]string{"a", "b"}, "c", nil}
+		Expect(GeneratedSlice[string]([]any{elems}, 0)).To(Equal([]string{"a", "b", "c", ""}))
+		Expect(GeneratedSlice[string]([]any{[]string{"d"}}, 0)).To(Equal([]string{"d"}))
+		Expect(GeneratedSlice[string]([]any{nil}, 0)).To(BeNil())
+	})
+	It("nil argument", func() {
+		Expect(GeneratedArg[error]([]any{nil}, 0)).To(BeNil())
+	})
+})
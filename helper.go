@@ -17,13 +17,17 @@ package piper
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"strings"
 )
 
+// The build information set with ldflags, e.g.
+// -X github.com/go-piper/piper.Version=v1.0.0. The empty ones will be read from the
+// information embedded by go build.
 var (
-	Version   = "dev"
-	BuildTime = "unkown"
-	GitCommit = "unkown"
+	Version   string
+	BuildTime string
+	GitCommit string
 )
 
 const (
@@ -31,6 +35,10 @@ const (
 	piper         = "piper"
 	keyProfile    = "profile"
 	keyConfigName = "config"
+
+	keyConfigLocation           = "piper.config.location"
+	keyConfigAdditionalLocation = "piper.config.additional-location"
+	keyStartupTiming            = "piper.startup.timing"
 )
 
 // Panicf makes panic with format support.
@@ -39,10 +47,21 @@ func Panicf(format string, a ...interface{}) {
 	panic(err)
 }
 
+// callerLocation returns the file and line of the caller, skip is the number of stack
+// frames to skip, with 0 identifying the caller of callerLocation.
+func callerLocation(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
 // ExpandEnv replaces ${var} or ${var:-def} in the string with environment variables.
 func ExpandEnv(s string) string {
 	length := len(s)
-	if s[0] != '$' || s[1] != '{' || s[length-1] != '}' {
+	if length < 3 || s[0] != '$' || s[1] != '{' || s[length-1] != '}' {
 		return s
 	}
 	s = s[2 : length-1]
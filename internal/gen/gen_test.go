@@ -0,0 +1,99 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gen
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGen(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "gen test")
+}
+
+var _ = Describe("gen", func() {
+	It("generate providers", func() {
+		graph, err := Load("", "./testdata/app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(graph.Profiles()).To(Equal([]string{"dev"}))
+
+		files, err := graph.Generate("piper_gen.go", "", "dev")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		for path, src := range files {
+			Expect(path).To(HaveSuffix("testdata/app/piper_gen.go"))
+			Expect(string(src)).To(ContainSubstring(`"io"`))
+			Expect(string(src)).To(ContainSubstring("return newService(" +
+				"piper.GeneratedArg[*Repo](args, 0), piper.GeneratedSlice[Handler](args, 1), " +
+				"piper.GeneratedArg[io.Writer](args, 2), piper.GeneratedArg[int](args, 3))"))
+			Expect(string(src)).NotTo(ContainSubstring("func literal"))
+			Expect(string(src)).To(MatchRegexp(`(?s)newDevRepo".*newHandler".*newRepo".*newService"`))
+			// the graph of both profiles in order, the func literal is unnamed
+			Expect(string(src)).To(MatchRegexp(`(?s)Profile: "",.*Profile: "dev",`))
+			Expect(string(src)).To(MatchRegexp(`(?s)app.newHandler", Caller: "app.go:\d+"},` +
+				`.*app.newRepo", Caller: "app.go:\d+", Args: \[\]\[\]int{{\d+}}},` +
+				`.*{Name: "", Caller: "app.go:\d+"},` +
+				`.*app.newService", Caller: "app.go:\d+", ` +
+				`Args: \[\]\[\]int{{\d+}, {\d+, \d+}, nil, nil}}`))
+		}
+	})
+	It("run generated graph", func() {
+		graph, err := Load("", "./testdata/app", "./testdata/run")
+		Expect(err).NotTo(HaveOccurred())
+
+		files, err := graph.Generate("piper_gen.go", "", "dev")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(2))
+		for path, src := range files {
+			Expect(os.WriteFile(path, src, 0644)).To(Succeed())
+			defer os.Remove(path)
+		}
+
+		run := func(args ...string) []string {
+			cmd := exec.Command("go", append([]string{"run", "./testdata/run"}, args...)...)
+			out, err := cmd.Output()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+			for _, line := range strings.Split(string(out), "\n") {
+				if strings.HasPrefix(line, "calls:") {
+					return strings.Fields(line)[1:]
+				}
+			}
+			return nil
+		}
+		// all the providers are instantiated in order when starting without reflection
+		Expect(run("start")).To(Equal([]string{"newHandler", "newRepo", "newService:2"}))
+		Expect(run("start", "-p", "dev")).To(Equal([]string{"newHandler", "newRepo",
+			"newDevRepo", "newService:2"}))
+	})
+	It("report errors", func() {
+		graph, err := Load("", "./testdata/broken")
+		Expect(err).NotTo(HaveOccurred())
+
+		err = graph.Resolve("", "prod")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("broken.newService: more than one " +
+			"dependencies of *github.com/go-piper/piper/internal/gen/testdata/broken.Repo " +
+			"found: broken.newRepoA, broken.newRepoB"))
+		Expect(err.Error()).To(ContainSubstring("broken.newService: no dependency of " +
+			"*github.com/go-piper/piper.AppEnv found"))
+		Expect(err.Error()).NotTo(ContainSubstring("(profile"))
+	})
+})
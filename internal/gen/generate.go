@@ -0,0 +1,322 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-piper/piper/internal/wireopt"
+)
+
+// buildTag excludes the generated files when analyzing, so the stale generated code
+// will not break the analysis.
+const buildTag = "piper_gen"
+
+// Generate resolves the dependencies for each profile, and generates the code which
+// constructs the graph, keyed by the path of file with the given name. The graphs of
+// all the profiles are generated in the main package, or the first package if no main
+// package found, and the func providers are generated in the packages where they were
+// wired, since the unexported ones can only be called there.
+//
+// The container uses the graph of active profile instead of resolving dependencies,
+// and instantiates the providers in the order of graph when starting. The providers
+// which cannot be named in generated code, such as func literals, are still called
+// with reflection. If the providers wired no longer match the graph, the container
+// falls back to resolving at runtime, so the stale generated code is never used.
+func (g *Graph) Generate(filename string, profiles ...string) (map[string][]byte, error) {
+	if err := g.Resolve(profiles...); err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		profiles = []string{""}
+	}
+	if len(g.pkgs) == 0 {
+		return map[string][]byte{}, nil
+	}
+	host := g.host()
+
+	// collect the providers used in any of the profiles
+	seen := make(map[*provider]bool)
+	pkgProviders := make(map[*pkg][]*provider)
+	plans := make([]*plan, 0, len(profiles))
+	for _, profile := range profiles {
+		r := g.resolve(profile)
+		plans = append(plans, newPlan(r))
+		for _, p := range r.order {
+			if seen[p] || p.fn == nil || !r.active(p) {
+				continue
+			}
+			seen[p] = true
+
+			// the providers of piper can only be called if exported
+			target := p.pkg
+			if p.pkg.path == wireopt.PiperPkg {
+				if !p.fn.Exported() {
+					continue
+				}
+				target = host
+			}
+			pkgProviders[target] = append(pkgProviders[target], p)
+		}
+	}
+
+	files := make(map[string][]byte)
+	for _, p := range g.pkgs {
+		var pkgPlans []*plan
+		if p == host {
+			pkgPlans = plans
+		} else if len(pkgProviders[p]) == 0 {
+			continue
+		}
+		src, err := generateFile(p, pkgProviders[p], pkgPlans)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Join(p.dir, filename)] = src
+	}
+
+	return files, nil
+}
+
+// host returns the package to generate graphs in, which is the main package if found.
+func (g *Graph) host() *pkg {
+	pkgs := append([]*pkg(nil), g.pkgs...)
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].path < pkgs[j].path
+	})
+	for _, p := range pkgs {
+		if p.name == "main" {
+			return p
+		}
+	}
+
+	return pkgs[0]
+}
+
+// plan is the graph of all the providers active in a profile in dependency order.
+type plan struct {
+	profile string
+	nodes   []*provider
+	args    map[*provider][][]*provider
+}
+
+func newPlan(r *resolver) *plan {
+	pl := &plan{
+		profile: r.profile,
+		args:    r.args,
+	}
+	// the fields have no dependencies, so they go first
+	for _, p := range r.graph.nodes {
+		if !p.isFunc && r.active(p) {
+			pl.nodes = append(pl.nodes, p)
+		}
+	}
+	for _, p := range r.order {
+		if r.active(p) {
+			pl.nodes = append(pl.nodes, p)
+		}
+	}
+
+	return pl
+}
+
+// write writes the graph as piper.GeneratedGraph.
+func (pl *plan) write(buffer *bytes.Buffer) {
+	index := make(map[*provider]int)
+	buffer.WriteString(fmt.Sprintf("&piper.GeneratedGraph{\nProfile: %q,\n", pl.profile))
+	buffer.WriteString("Nodes: []piper.GeneratedNode{\n")
+	for i, p := range pl.nodes {
+		index[p] = i
+		name := p.name
+		if p.isFunc && p.fn == nil {
+			name = ""
+		}
+		buffer.WriteString(fmt.Sprintf("{Name: %q, Caller: %q", name, p.caller))
+		if p.isFunc && len(p.params) != 0 {
+			args := make([]string, 0, len(p.params))
+			for _, arg := range pl.args[p] {
+				if arg == nil {
+					args = append(args, "nil")
+					continue
+				}
+				indexes := make([]string, 0, len(arg))
+				for _, dep := range arg {
+					indexes = append(indexes, fmt.Sprint(index[dep]))
+				}
+				args = append(args, "{"+strings.Join(indexes, ", ")+"}")
+			}
+			buffer.WriteString(fmt.Sprintf(", Args: [][]int{%s}", strings.Join(args, ", ")))
+		}
+		buffer.WriteString("},\n")
+	}
+	buffer.WriteString("},\n},\n")
+}
+
+// generateFile generates the code of providers and graphs in package.
+func generateFile(pkg *pkg, providers []*provider, plans []*plan) ([]byte, error) {
+	// the order makes no difference in map, sort it to keep the generated code stable
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].name < providers[j].name
+	})
+	imports := newImports(pkg.types)
+	body := new(bytes.Buffer)
+	for _, p := range providers {
+		accessible := true
+		for _, param := range p.params {
+			accessible = accessible && imports.accessible(param)
+		}
+		// the type of parameter cannot be named, leave it to the container
+		if !accessible {
+			continue
+		}
+
+		args := make([]string, 0, len(p.params))
+		for i, param := range p.params {
+			// the collection is passed as []any of the elements
+			if slice, ok := param.(*types.Slice); ok {
+				args = append(args, fmt.Sprintf("piper.GeneratedSlice[%s](args, %d)",
+					types.TypeString(slice.Elem(), imports.qualifier), i))
+				continue
+			}
+			args = append(args, fmt.Sprintf("piper.GeneratedArg[%s](args, %d)",
+				types.TypeString(param, imports.qualifier), i))
+		}
+
+		fn := p.fn.Name()
+		if p.fn.Pkg() != pkg.types {
+			fn = imports.qualifier(p.fn.Pkg()) + "." + fn
+		}
+		body.WriteString(fmt.Sprintf("%q: func(args []any) any {\nreturn %s(%s)\n},\n",
+			p.name, fn, strings.Join(args, ", ")))
+	}
+
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("// Code generated by piper gen. DO NOT EDIT.\n\n")
+	buffer.WriteString(fmt.Sprintf("//go:build !%s\n\n", buildTag))
+	buffer.WriteString(fmt.Sprintf("package %s\n\n", pkg.name))
+	buffer.WriteString("import (\n")
+	for _, path := range imports.paths() {
+		if imports.aliased[path] {
+			buffer.WriteString(imports.names[path] + " ")
+		}
+		buffer.WriteString(fmt.Sprintf("%q\n", path))
+	}
+	buffer.WriteString(")\n\n")
+	buffer.WriteString("func init() {\n")
+	if body.Len() != 0 {
+		buffer.WriteString("piper.UseGenerated(map[string]piper.GeneratedProvider{\n")
+		buffer.WriteString(body.String())
+		buffer.WriteString("})\n")
+	}
+	if len(plans) != 0 {
+		buffer.WriteString("piper.UseGeneratedGraph(\n")
+		for _, pl := range plans {
+			pl.write(buffer)
+		}
+		buffer.WriteString(")\n")
+	}
+	buffer.WriteString("}\n")
+
+	return format.Source(buffer.Bytes())
+}
+
+// imports records the packages imported by generated code.
+type imports struct {
+	pkg     *types.Package
+	names   map[string]string
+	aliased map[string]bool
+	used    map[string]bool
+}
+
+func newImports(pkg *types.Package) *imports {
+	return &imports{
+		pkg:     pkg,
+		names:   map[string]string{wireopt.PiperPkg: "piper"},
+		aliased: make(map[string]bool),
+		used:    map[string]bool{"piper": true},
+	}
+}
+
+// qualifier returns the name of package in generated code, and imports it.
+func (i *imports) qualifier(pkg *types.Package) string {
+	if pkg == i.pkg {
+		return ""
+	}
+	if name, ok := i.names[pkg.Path()]; ok {
+		return name
+	}
+
+	name := pkg.Name()
+	for n := 2; i.used[name]; n++ {
+		name = fmt.Sprintf("%s%d", pkg.Name(), n)
+	}
+	i.names[pkg.Path()] = name
+	i.aliased[pkg.Path()] = name != pkg.Name()
+	i.used[name] = true
+
+	return name
+}
+
+// accessible checks if the type can be named in generated code.
+func (i *imports) accessible(t types.Type) bool {
+	switch u := t.(type) {
+	case *types.Basic:
+		return true
+	case *types.Named:
+		obj := u.Obj()
+		if obj.Pkg() != nil && obj.Pkg() != i.pkg && !obj.Exported() {
+			return false
+		}
+		if u.TypeArgs() != nil {
+			for n := 0; n < u.TypeArgs().Len(); n++ {
+				if !i.accessible(u.TypeArgs().At(n)) {
+					return false
+				}
+			}
+		}
+		return obj.Pkg() == nil || obj.Pkg().Name() != "main" || obj.Pkg() == i.pkg
+	case *types.Pointer:
+		return i.accessible(u.Elem())
+	case *types.Slice:
+		return i.accessible(u.Elem())
+	case *types.Array:
+		return i.accessible(u.Elem())
+	case *types.Chan:
+		return i.accessible(u.Elem())
+	case *types.Map:
+		return i.accessible(u.Key()) && i.accessible(u.Elem())
+	case *types.Interface:
+		return u.Empty()
+	}
+
+	return false
+}
+
+// paths returns the sorted paths of imported packages.
+func (i *imports) paths() []string {
+	paths := make([]string, 0, len(i.names))
+	for path := range i.names {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
@@ -0,0 +1,442 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gen statically analyzes the Wire and WireWithOption calls in packages,
+// resolves the dependencies as the runtime container does, and generates the code
+// which constructs the graph and providers without reflection.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-piper/piper/internal/wireopt"
+)
+
+// Graph is the wiring graph found in packages.
+type Graph struct {
+	fset      *token.FileSet
+	pkgs      []*pkg
+	providers map[key][]*provider
+	// nodes are all the providers in the order they were wired
+	nodes []*provider
+	// errs are the errors found when analyzing Wire calls
+	errs []error
+}
+
+// key is the same as ProviderKey in container.
+type key struct {
+	name      string
+	alias     string
+	isPointer bool
+}
+
+func (k key) String() string {
+	name := k.name
+	if k.isPointer {
+		name = "*" + name
+	}
+	if len(k.alias) != 0 {
+		name = fmt.Sprintf("%s(%s)", name, k.alias)
+	}
+
+	return name
+}
+
+// provider is a provider wired in a package.
+type provider struct {
+	pkg *pkg
+	pos token.Pos
+	// caller is the file name and line where it was wired, e.g. app.go:42
+	caller string
+	name   string
+	// fn is the provider func, nil if it is a field or it cannot be generated
+	fn     *types.Func
+	isFunc bool
+	params []types.Type
+	key    key
+	inOpts []*wireopt.Option
+	outOpt *wireopt.Option
+}
+
+// pkg is the package type checked from source.
+type pkg struct {
+	path  string
+	name  string
+	dir   string
+	files []*ast.File
+	types *types.Package
+	info  *types.Info
+}
+
+// listedPkg is the package listed by go list.
+type listedPkg struct {
+	Dir        string
+	ImportPath string
+	Name       string
+	GoFiles    []string
+	Imports    []string
+	Error      *struct {
+		Err string
+	}
+}
+
+// Load loads the packages matching patterns in dir, and finds all the providers wired.
+// The piper package is always loaded, since it wires providers itself.
+func Load(dir string, patterns ...string) (*Graph, error) {
+	// exclude the generated files, which may be stale
+	if !hasTag(build.Default.BuildTags, buildTag) {
+		build.Default.BuildTags = append(build.Default.BuildTags, buildTag)
+	}
+
+	args := append([]string{"list", "-e", "-json", "-tags=" + buildTag}, patterns...)
+	cmd := exec.Command("go", append(args, wireopt.PiperPkg)...)
+	cmd.Dir = dir
+	cmd.Stderr = new(bytes.Buffer)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %v: %s", err, cmd.Stderr)
+	}
+
+	listed := make([]*listedPkg, 0)
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		lp := new(listedPkg)
+		if err := decoder.Decode(lp); err != nil {
+			return nil, err
+		}
+		if lp.Error != nil {
+			return nil, errors.New(lp.Error.Err)
+		}
+		listed = append(listed, lp)
+	}
+
+	l := &loader{
+		fset:     token.NewFileSet(),
+		listed:   make(map[string]*listedPkg),
+		pkgs:     make(map[string]*pkg),
+		fallback: importer.ForCompiler(token.NewFileSet(), "source", nil).(types.ImporterFrom),
+	}
+	for _, lp := range listed {
+		l.listed[lp.ImportPath] = lp
+	}
+
+	g := &Graph{
+		fset:      l.fset,
+		providers: make(map[key][]*provider),
+	}
+	// packages are initialized in dependency order, so do the providers
+	paths := make([]string, 0, len(l.listed))
+	for path := range l.listed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, err := l.load(path); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range l.order {
+		g.collect(p)
+		if p.path != wireopt.PiperPkg {
+			g.pkgs = append(g.pkgs, p)
+		}
+	}
+
+	return g, nil
+}
+
+// loader type checks the listed packages from source. The packages not listed are
+// imported with the source importer.
+type loader struct {
+	fset     *token.FileSet
+	listed   map[string]*listedPkg
+	pkgs     map[string]*pkg
+	order    []*pkg
+	fallback types.ImporterFrom
+}
+
+func (l *loader) Import(path string) (*types.Package, error) {
+	return l.ImportFrom(path, "", 0)
+}
+
+func (l *loader) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package,
+	error) {
+	if _, ok := l.listed[path]; ok {
+		p, err := l.load(path)
+		if err != nil {
+			return nil, err
+		}
+		return p.types, nil
+	}
+
+	return l.fallback.ImportFrom(path, dir, mode)
+}
+
+// load parses and type checks the listed package after its imports.
+func (l *loader) load(path string) (*pkg, error) {
+	if p, ok := l.pkgs[path]; ok {
+		if p.types == nil {
+			return nil, fmt.Errorf("import cycle found in %s", path)
+		}
+		return p, nil
+	}
+
+	lp := l.listed[path]
+	p := &pkg{
+		path: lp.ImportPath,
+		name: lp.Name,
+		dir:  lp.Dir,
+		info: &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		},
+	}
+	l.pkgs[path] = p
+	for _, name := range lp.GoFiles {
+		file, err := parser.ParseFile(l.fset, filepath.Join(lp.Dir, name), nil,
+			parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.files = append(p.files, file)
+	}
+
+	messages := make([]string, 0)
+	cfg := &types.Config{
+		Importer: l,
+		Error: func(err error) {
+			messages = append(messages, err.Error())
+		},
+	}
+	p.types, _ = cfg.Check(path, l.fset, p.files, p.info)
+	if len(messages) != 0 {
+		return nil, errors.New(strings.Join(messages, "\n"))
+	}
+	l.order = append(l.order, p)
+
+	return p, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// collect finds the Wire and WireWithOption calls in package.
+func (g *Graph) collect(p *pkg) {
+	for _, file := range p.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			switch wireopt.Func(p.info, call.Fun) {
+			case "Wire":
+				if call.Ellipsis.IsValid() {
+					g.errs = append(g.errs, g.errorf(call.Pos(),
+						"providers passed with ... cannot be analyzed"))
+					return true
+				}
+				for _, arg := range call.Args {
+					g.add(p, call, arg, nil)
+				}
+			case "WireWithOption":
+				if len(call.Args) == 0 || call.Ellipsis.IsValid() {
+					g.errs = append(g.errs, g.errorf(call.Pos(),
+						"options passed with ... cannot be analyzed"))
+					return true
+				}
+				g.add(p, call, call.Args[0], call.Args[1:])
+			}
+
+			return true
+		})
+	}
+}
+
+// add adds the provider wired in call with options.
+func (g *Graph) add(pkg *pkg, call *ast.CallExpr, expr ast.Expr, optExprs []ast.Expr) {
+	info := pkg.info
+	tv, ok := info.Types[expr]
+	if !ok || tv.IsNil() {
+		g.errs = append(g.errs, g.errorf(expr.Pos(), "provider cannot be nil"))
+		return
+	}
+
+	// the same as the caller location recorded by container
+	position := g.fset.Position(call.Pos())
+	p := &provider{
+		pkg:    pkg,
+		pos:    expr.Pos(),
+		caller: fmt.Sprintf("%s:%d", filepath.Base(position.Filename), position.Line),
+	}
+	opts := make([]*wireopt.Option, 0, len(optExprs))
+	for _, e := range optExprs {
+		opt, err := wireopt.Eval(info, e)
+		if err == nil && !opt.Constant {
+			err = errors.New("the argument of wire option should be constant string")
+		}
+		if err != nil {
+			g.errs = append(g.errs, g.errorf(e.Pos(), "%v", err))
+			return
+		}
+		opts = append(opts, opt)
+	}
+
+	sig, ok := tv.Type.Underlying().(*types.Signature)
+	if !ok {
+		if len(opts) > 1 || len(opts) == 1 && (!opts[0].Out || opts[0].Validate() != nil) {
+			g.errs = append(g.errs, g.errorf(expr.Pos(),
+				"only one wire out option can be passed to wire"))
+			return
+		}
+		if len(opts) == 1 {
+			p.outOpt = opts[0]
+		}
+		p.key = keyOf(tv.Type, outName(p.outOpt))
+		p.name = p.key.name
+		g.addProvider(p)
+		return
+	}
+
+	p.isFunc = true
+	if sig.Results().Len() != 1 {
+		g.errs = append(g.errs, g.errorf(expr.Pos(),
+			"no or more than one out parameter for the given provider"))
+		return
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		p.params = append(p.params, sig.Params().At(i).Type())
+	}
+	inOpts, outOpt, index, err := wireopt.Split(opts, len(p.params))
+	if err != nil {
+		g.errs = append(g.errs, g.errorf(optExprs[index].Pos(), "%v", err))
+		return
+	}
+	p.inOpts = inOpts
+	p.outOpt = outOpt
+	p.key = keyOf(sig.Results().At(0).Type(), outName(outOpt))
+
+	// only the package level func can be named and called in generated code
+	if fn := funcObject(info, expr); fn != nil && !sig.Variadic() &&
+		sig.TypeParams() == nil {
+		p.fn = fn
+		p.name = pkgPath(fn.Pkg()) + "." + fn.Name()
+	} else {
+		p.name = fmt.Sprintf("%s (func literal)", g.fset.Position(expr.Pos()))
+	}
+	g.addProvider(p)
+}
+
+// outName returns the name in out option, or empty.
+func outName(outOpt *wireopt.Option) string {
+	if outOpt != nil {
+		return outOpt.Name
+	}
+
+	return ""
+}
+
+func (g *Graph) addProvider(p *provider) {
+	g.providers[p.key] = append(g.providers[p.key], p)
+	g.nodes = append(g.nodes, p)
+}
+
+func (g *Graph) errorf(pos token.Pos, format string, a ...any) error {
+	return fmt.Errorf("%s: %s", g.fset.Position(pos), fmt.Sprintf(format, a...))
+}
+
+// funcObject returns the package level func referred by expression.
+func funcObject(info *types.Info, expr ast.Expr) *types.Func {
+	var ident *ast.Ident
+	switch e := expr.(type) {
+	case *ast.Ident:
+		ident = e
+	case *ast.SelectorExpr:
+		// method value or method expression cannot be named
+		if _, ok := info.Selections[e]; ok {
+			return nil
+		}
+		ident = e.Sel
+	default:
+		return nil
+	}
+
+	fn, ok := info.Uses[ident].(*types.Func)
+	if !ok || fn.Type().(*types.Signature).Recv() != nil {
+		return nil
+	}
+
+	return fn
+}
+
+// keyOf returns the key of type in the same way as ParseFieldType does.
+func keyOf(t types.Type, alias string) key {
+	realType := t
+	switch u := t.(type) {
+	case *types.Pointer:
+		realType = u.Elem()
+	case *types.Slice:
+		realType = u.Elem()
+	}
+	_, isPointer := t.(*types.Pointer)
+
+	return key{
+		name:      typeName(realType),
+		alias:     alias,
+		isPointer: isPointer,
+	}
+}
+
+// typeName returns the package qualified name of type as reflection does, e.g.
+// github.com/acme/db.Pool, the unnamed type has no package, e.g. .*db.Pool.
+func typeName(t types.Type) string {
+	if named, ok := t.(*types.Named); ok && named.Obj().Pkg() != nil {
+		return pkgPath(named.Obj().Pkg()) + "." + named.Obj().Name()
+	}
+
+	return "." + types.TypeString(t, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+// pkgPath returns the path of package as reflection does, which is main for main package.
+func pkgPath(pkg *types.Package) string {
+	if pkg.Name() == "main" {
+		return "main"
+	}
+
+	return pkg.Path()
+}
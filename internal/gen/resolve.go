@@ -0,0 +1,270 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gen
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/go-piper/piper/internal/wireopt"
+)
+
+// resolver resolves the dependencies for one profile in the same way as container.
+type resolver struct {
+	graph    *Graph
+	profile  string
+	resolved map[*provider]bool
+	failed   map[*provider]bool
+	// order is the func providers in dependency order
+	order []*provider
+	// args are the providers resolved for each in parameter, all the active ones for
+	// collection, or nil if the default value is used
+	args map[*provider][][]*provider
+	errs []error
+}
+
+// Profiles returns all the profiles used in Active options.
+func (g *Graph) Profiles() []string {
+	seen := make(map[string]bool)
+	profiles := make([]string, 0)
+	for _, p := range g.nodes {
+		if p.outOpt == nil {
+			continue
+		}
+		for _, profile := range p.outOpt.Profiles {
+			if !seen[profile] {
+				seen[profile] = true
+				profiles = append(profiles, profile)
+			}
+		}
+	}
+	sort.Strings(profiles)
+
+	return profiles
+}
+
+// Resolve resolves the dependencies for each profile, and returns all the errors
+// found. The error found only in some of profiles will be suffixed with them.
+func (g *Graph) Resolve(profiles ...string) error {
+	if len(profiles) == 0 {
+		profiles = []string{""}
+	}
+
+	messages := make([]string, 0)
+	for _, err := range g.errs {
+		messages = append(messages, err.Error())
+	}
+
+	// the same error may be found in several profiles
+	errProfiles := make(map[string][]string)
+	resolveMessages := make([]string, 0)
+	for _, profile := range profiles {
+		r := g.resolve(profile)
+		for _, err := range r.errs {
+			msg := err.Error()
+			if _, ok := errProfiles[msg]; !ok {
+				resolveMessages = append(resolveMessages, msg)
+			}
+			errProfiles[msg] = append(errProfiles[msg], fmt.Sprintf("%q", profile))
+		}
+	}
+	for _, msg := range resolveMessages {
+		if len(errProfiles[msg]) != len(profiles) {
+			msg = fmt.Sprintf("%s (profile %s)", msg, strings.Join(errProfiles[msg], ", "))
+		}
+		messages = append(messages, msg)
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(messages, "\n"))
+}
+
+// resolve resolves all the func providers for profile.
+func (g *Graph) resolve(profile string) *resolver {
+	r := &resolver{
+		graph:    g,
+		profile:  profile,
+		resolved: make(map[*provider]bool),
+		failed:   make(map[*provider]bool),
+		args:     make(map[*provider][][]*provider),
+	}
+	for _, p := range g.nodes {
+		if !p.isFunc {
+			r.resolved[p] = true
+		}
+	}
+	for _, p := range g.nodes {
+		r.resolveNode(p, nil)
+	}
+
+	return r
+}
+
+func (r *resolver) active(p *provider) bool {
+	if p.outOpt == nil || len(p.outOpt.Profiles) == 0 {
+		return true
+	}
+	for _, profile := range p.outOpt.Profiles {
+		if profile == r.profile {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *resolver) errorf(p *provider, format string, a ...any) {
+	r.errs = append(r.errs, r.graph.errorf(p.pos, "%s: %s", shortName(p.name),
+		fmt.Sprintf(format, a...)))
+}
+
+// resolveNode resolves the provider, and returns false if it cannot be resolved.
+func (r *resolver) resolveNode(p *provider, chain []*provider) bool {
+	if r.resolved[p] || r.failed[p] {
+		return r.resolved[p]
+	}
+
+	chain = append(chain, p)
+	ok := true
+	args := make([][]*provider, len(p.params))
+	for i, param := range p.params {
+		var inOpt *wireopt.Option
+		var alias string
+		if i < len(p.inOpts) {
+			inOpt = p.inOpts[i]
+			alias = inOpt.Name
+		}
+		k := keyOf(param, alias)
+		nodes := r.graph.providers[k]
+
+		if len(nodes) == 0 {
+			ok = r.resolveDefault(p, param, k, inOpt) && ok
+			continue
+		}
+
+		if _, isSlice := param.(*types.Slice); isSlice {
+			args[i] = make([]*provider, 0)
+			for _, n := range nodes {
+				if r.active(n) {
+					ok = r.resolveChild(n, chain) && ok
+					args[i] = append(args[i], n)
+				}
+			}
+			continue
+		}
+
+		var primary *provider
+		if len(nodes) > 1 {
+			for _, n := range nodes {
+				if n.outOpt != nil && n.outOpt.Primary {
+					primary = n
+					break
+				}
+			}
+			if primary == nil {
+				names := make([]string, 0, len(nodes))
+				for _, n := range nodes {
+					names = append(names, shortName(n.name))
+				}
+				r.errorf(p, "more than one dependencies of %s found: %s", k,
+					strings.Join(names, ", "))
+				ok = false
+				continue
+			}
+		} else {
+			primary = nodes[0]
+		}
+
+		if !r.active(primary) {
+			r.errorf(p, "no dependency of %s found", k)
+			ok = false
+			continue
+		}
+		ok = r.resolveChild(primary, chain) && ok
+		args[i] = []*provider{primary}
+	}
+
+	if !ok {
+		r.failed[p] = true
+		return false
+	}
+	r.resolved[p] = true
+	r.order = append(r.order, p)
+	r.args[p] = args
+
+	return true
+}
+
+func (r *resolver) resolveChild(p *provider, chain []*provider) bool {
+	if r.resolved[p] || r.failed[p] {
+		return r.resolved[p]
+	}
+
+	for i, n := range chain {
+		if n != p {
+			continue
+		}
+		names := make([]string, 0, len(chain)-i+1)
+		for _, c := range append(chain[i:], p) {
+			names = append(names, shortName(c.name))
+		}
+		r.errorf(chain[len(chain)-1], "cycle dependencies found: %s",
+			strings.Join(names, " -> "))
+		return false
+	}
+
+	return r.resolveNode(p, chain)
+}
+
+// resolveDefault checks the default value when no provider found.
+func (r *resolver) resolveDefault(p *provider, param types.Type, k key,
+	inOpt *wireopt.Option) bool {
+	var defType types.Type
+	if inOpt != nil && inOpt.Default != nil {
+		defType = p.pkg.info.Types[inOpt.Default].Type
+	}
+	if inOpt == nil || inOpt.Required || defType == nil ||
+		types.Identical(defType, types.Typ[types.UntypedNil]) {
+		r.errorf(p, "no dependency of %s found", k)
+		return false
+	}
+	if _, ok := defType.Underlying().(*types.Signature); ok {
+		r.errorf(p, "default value cannot be func")
+		return false
+	}
+	if keyOf(defType, "") != keyOf(param, "") &&
+		!types.AssignableTo(defType, param) &&
+		!types.ConvertibleTo(defType, param) {
+		r.errorf(p, "the default value %s is not match %s", defType, param)
+		return false
+	}
+
+	return true
+}
+
+// shortName strips the package path of the qualified name.
+func shortName(name string) string {
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		return name[index+1:]
+	}
+
+	return name
+}
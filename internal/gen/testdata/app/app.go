@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/go-piper/piper"
+)
+
+// Calls records the providers called in order, suffixed with (reflect) if called
+// with reflection.
+var Calls []string
+
+type Config struct {
+	Name string
+}
+
+type Repo struct {
+}
+
+type Handler struct {
+}
+
+type Service struct {
+}
+
+func newRepo(_ *Config) *Repo {
+	record("newRepo")
+	return &Repo{}
+}
+
+func newDevRepo() *Repo {
+	record("newDevRepo")
+	return &Repo{}
+}
+
+func newHandler() Handler {
+	record("newHandler")
+	return Handler{}
+}
+
+func newService(_ *Repo, handlers []Handler, _ io.Writer, _ int) *Service {
+	record(fmt.Sprintf("newService:%d", len(handlers)))
+	return &Service{}
+}
+
+func record(name string) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "reflect.Value.call" {
+			name += " (reflect)"
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	Calls = append(Calls, name)
+}
+
+func init() {
+	piper.Wire(&Config{}, newHandler)
+	piper.WireWithOption(newRepo, piper.Out().Primary())
+	piper.WireWithOption(newDevRepo, piper.Active("dev"))
+	piper.WireWithOption(newService, piper.In(), piper.In(), piper.Default(io.Discard),
+		piper.Default(8080))
+	piper.Wire(func() Handler { return Handler{} })
+}
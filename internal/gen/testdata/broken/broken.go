@@ -0,0 +1,32 @@
+package broken
+
+import (
+	"github.com/go-piper/piper"
+)
+
+type Repo struct {
+}
+
+type Service struct {
+}
+
+func newRepoA() *Repo {
+	return &Repo{}
+}
+
+func newRepoB() *Repo {
+	return &Repo{}
+}
+
+func newService(_ *Repo, _ *piper.AppEnv) *Service {
+	return &Service{}
+}
+
+func newProdService() *Service {
+	return &Service{}
+}
+
+func init() {
+	piper.Wire(newRepoA, newRepoB, newService)
+	piper.WireWithOption(newProdService, piper.OutName("prod"), piper.Active("prod"))
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-piper/piper"
+	"github.com/go-piper/piper/internal/gen/testdata/app"
+)
+
+//go:embed resources
+var resources embed.FS
+
+func main() {
+	p := piper.NewPiper(func(opt *piper.Option) {
+		opt.Description = "run the generated graph"
+		opt.ResourceFs = resources
+	})
+	if err := p.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("calls:", strings.Join(app.Calls, " "))
+}
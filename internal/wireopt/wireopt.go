@@ -0,0 +1,200 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wireopt evaluates the Wire, WireWithOption calls and wire options of piper
+// statically, in the same way as the container does at runtime. It is shared by piper
+// gen and wirecheck, so they will always agree with each other.
+package wireopt
+
+import (
+	"errors"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+)
+
+// PiperPkg is the path of piper package.
+const PiperPkg = "github.com/go-piper/piper"
+
+// methodPrefix is the prefix of the methods of WireOption returned by Func.
+const methodPrefix = "(*WireOption)."
+
+// Option is the wire option evaluated statically.
+type Option struct {
+	// Call is the outermost call of option expression.
+	Call     *ast.CallExpr
+	Out      bool
+	Name     string
+	Required bool
+	Primary  bool
+	Profiles []string
+	// Default is the expression of default value, nil if no default value.
+	Default ast.Expr
+	// Constant reports whether the name and profiles are constant strings, they are
+	// empty if not.
+	Constant bool
+}
+
+// Eval evaluates the wire option expression, such as piper.Name("a") or
+// piper.Out().Primary(). Only the convenient funcs decide if the option is out, the
+// methods of WireOption do not change it.
+func Eval(info *types.Info, expr ast.Expr) (*Option, error) {
+	call, ok := Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return nil, errors.New("wire option should be written inline to be analyzed")
+	}
+
+	name := Func(info, call.Fun)
+	method := strings.HasPrefix(name, methodPrefix)
+	var opt *Option
+	if method {
+		var err error
+		if opt, err = Eval(info, call.Fun.(*ast.SelectorExpr).X); err != nil {
+			return nil, err
+		}
+		name = strings.TrimPrefix(name, methodPrefix)
+	} else {
+		opt = &Option{Required: true, Constant: true}
+	}
+	opt.Call = call
+
+	switch name {
+	case "In":
+	case "Out", "Lazy":
+		opt.Out = true
+	case "Name", "OutName":
+		value, ok := stringArg(info, call.Args[0])
+		opt.Constant = opt.Constant && ok
+		if method {
+			value = strings.TrimSpace(value)
+		}
+		opt.Name = value
+		opt.Out = opt.Out || name == "OutName"
+	case "Default":
+		opt.Default = call.Args[0]
+		opt.Required = false
+	case "Primary":
+		opt.Primary = true
+		opt.Out = opt.Out || !method
+	case "Active":
+		if call.Ellipsis.IsValid() {
+			return nil, errors.New("profiles passed with ... cannot be analyzed")
+		}
+		opt.Profiles = make([]string, 0, len(call.Args))
+		for _, arg := range call.Args {
+			value, ok := stringArg(info, arg)
+			opt.Constant = opt.Constant && ok
+			opt.Profiles = append(opt.Profiles, value)
+		}
+		opt.Out = opt.Out || !method
+	default:
+		return nil, errors.New("wire option should be written inline to be analyzed")
+	}
+
+	return opt, nil
+}
+
+// Validate validates the option in the same way as WireOption does.
+func (o *Option) Validate() error {
+	if o.Out {
+		if !o.Required {
+			return errors.New("required option of wire out parameter cannot be false")
+		}
+		return nil
+	}
+
+	if o.Primary {
+		return errors.New("primary option of wire in parameter cannot exist")
+	}
+	if len(o.Profiles) != 0 {
+		return errors.New("active option of wire in parameter cannot exist")
+	}
+
+	return nil
+}
+
+// Split validates the options of provider with in parameters as validateWireOption
+// does, and splits them into in options and out option. It returns the index of the
+// invalid option if any error occurs.
+func Split(opts []*Option, numIn int) ([]*Option, *Option, int, error) {
+	inOpts := make([]*Option, 0, len(opts))
+	var outOpt *Option
+	for i, o := range opts {
+		if err := o.Validate(); err != nil {
+			return nil, nil, i, err
+		}
+		if o.Out && i < len(opts)-1 {
+			return nil, nil, i, errors.New("wire out option can only be the last option " +
+				"in provider")
+		}
+		if o.Out {
+			outOpt = o
+		} else {
+			inOpts = append(inOpts, o)
+		}
+	}
+
+	if len(inOpts) > numIn {
+		return nil, nil, numIn, errors.New("wire in option is more than the in " +
+			"parameters in provider")
+	}
+
+	return inOpts, outOpt, -1, nil
+}
+
+// Func returns the name of func in piper package which is called, or empty. The
+// methods of WireOption are prefixed with (*WireOption).
+func Func(info *types.Info, fun ast.Expr) string {
+	var ident *ast.Ident
+	switch f := Unparen(fun).(type) {
+	case *ast.Ident:
+		ident = f
+	case *ast.SelectorExpr:
+		ident = f.Sel
+	default:
+		return ""
+	}
+
+	fn, ok := info.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != PiperPkg {
+		return ""
+	}
+	if sig := fn.Type().(*types.Signature); sig.Recv() != nil {
+		return methodPrefix + fn.Name()
+	}
+
+	return fn.Name()
+}
+
+// Unparen returns the expression with any enclosing parentheses removed.
+func Unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
+
+// stringArg returns the value of constant string argument.
+func stringArg(info *types.Info, arg ast.Expr) (string, bool) {
+	tv := info.Types[arg]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(tv.Value), true
+}
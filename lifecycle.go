@@ -14,6 +14,10 @@
 
 package piper
 
+import (
+	"os"
+)
+
 // StartListener defines application start listener interface.
 type StartListener interface {
 	// OnAppStart indicates that the application has started.
@@ -25,3 +29,32 @@ type StopListener interface {
 	// OnAppStop indicates that the application has stopped.
 	OnAppStop()
 }
+
+// GraphResolvedEvent is published after all the dependencies resolved, before the
+// configuration loaded. It is delivered only to the listeners which have been
+// instantiated, such as the ones wired as instances, since the listeners built now
+// would see the properties not bound yet.
+type GraphResolvedEvent struct {
+}
+
+// ConfigLoadedEvent is published after configuration loaded and logging initialized,
+// before the config properties bound. Like GraphResolvedEvent, it is delivered only
+// to the listeners which have been instantiated.
+type ConfigLoadedEvent struct {
+	// Env is the application environment with configuration loaded.
+	Env *AppEnv
+}
+
+// EngineStartedEvent is published after all the StartListener invoked, right before
+// the engine starts to serve, since starting engine blocks until it stopped.
+type EngineStartedEvent struct {
+	// Engine is the name of engine.
+	Engine string
+}
+
+// ShutdownRequestedEvent is published when a signal is received to stop the
+// application, before the StopListener invoked.
+type ShutdownRequestedEvent struct {
+	// Signal is the received signal.
+	Signal os.Signal
+}
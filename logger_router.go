@@ -0,0 +1,233 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/coolerfall/slago"
+)
+
+const rootLoggerName = "root"
+
+// loggerRouter decides which writers output the logs of named logger. The level of
+// logger is inherited from its nearest parent with level, and the logs are output to
+// the writers of logger and its parents until the one which is not additive.
+type loggerRouter struct {
+	rootLevel slago.Level
+	levels    map[string]slago.Level
+	// loggers are keyed by name, and root is keyed by empty name
+	loggers map[string]*loggerWriters
+	// routes caches the *loggerRoute of each logger name
+	routes sync.Map
+}
+
+// loggerWriters is the writers attached to logger.
+type loggerWriters struct {
+	writers  []string
+	additive bool
+}
+
+// loggerRoute is the resolved level and writers of logger.
+type loggerRoute struct {
+	level   slago.Level
+	writers map[string]bool
+}
+
+func newLoggerRouter(config *LoggingProperty) *loggerRouter {
+	r := &loggerRouter{
+		rootLevel: slago.ParseLevel(config.Level),
+		levels:    make(map[string]slago.Level),
+		loggers:   make(map[string]*loggerWriters),
+	}
+	for name, level := range config.Levels {
+		if name = loggerName(name); name == "" {
+			r.rootLevel = slago.ParseLevel(level)
+		} else {
+			r.levels[name] = slago.ParseLevel(level)
+		}
+	}
+
+	attached := make(map[string]bool)
+	for _, l := range config.Loggers {
+		additive := l.Additive == nil || *l.Additive
+		r.loggers[loggerName(l.Name)] = &loggerWriters{writers: l.Writers, additive: additive}
+		for _, w := range l.Writers {
+			attached[w] = true
+		}
+	}
+	// the writers not attached to any logger are attached to root by default
+	if _, ok := r.loggers[""]; !ok {
+		root := &loggerWriters{}
+		for _, w := range config.Writers {
+			if !attached[w.Name] {
+				root.writers = append(root.writers, w.Name)
+			}
+		}
+		r.loggers[""] = root
+	}
+
+	return r
+}
+
+// minLevel returns the lowest level of all the loggers.
+func (r *loggerRouter) minLevel() slago.Level {
+	level := r.rootLevel
+	for _, l := range r.levels {
+		if l < level {
+			level = l
+		}
+	}
+
+	return level
+}
+
+// resolve resolves the level and writers of logger.
+func (r *loggerRouter) resolve(name string) *loggerRoute {
+	if route, ok := r.routes.Load(name); ok {
+		return route.(*loggerRoute)
+	}
+
+	route := &loggerRoute{
+		level:   r.rootLevel,
+		writers: make(map[string]bool),
+	}
+	levelFound := false
+	additive := true
+	for n := loggerName(name); ; n = parentLogger(n) {
+		if level, ok := r.levels[n]; ok && !levelFound {
+			route.level = level
+			levelFound = true
+		}
+		if l, ok := r.loggers[n]; ok && additive {
+			for _, w := range l.writers {
+				route.writers[w] = true
+			}
+			additive = l.additive
+		}
+		if n == "" {
+			break
+		}
+	}
+	r.routes.Store(name, route)
+
+	return route
+}
+
+// accept checks if the writer outputs the log of logger with level.
+func (r *loggerRouter) accept(writer, logger string, level slago.Level) bool {
+	route := r.resolve(logger)
+
+	return level >= route.level && route.writers[writer]
+}
+
+// route wraps the writer with name, so it only outputs the logs routed to it. The
+// async writer should be created with the routed writer as ref, then logs are
+// filtered in its worker.
+func (r *loggerRouter) route(name string, w slago.Writer) slago.Writer {
+	return &routedWriter{
+		Writer: w,
+		filter: &routeFilter{
+			router: r,
+			writer: name,
+		},
+	}
+}
+
+// isolate wraps the routed writer into its own MultiWriter. MultiWriter stops writing
+// to the rest of writers once a log is filtered, so every routed writer which is not
+// async should be isolated.
+func isolate(w slago.Writer) slago.Writer {
+	mw := slago.NewMultiWriter()
+	mw.AddWriter(w)
+
+	return &isolatedWriter{
+		MultiWriter: mw,
+	}
+}
+
+// routeFilter filters the logs which are not routed to writer.
+type routeFilter struct {
+	router *loggerRouter
+	writer string
+}
+
+func (f *routeFilter) Do(event *slago.LogEvent) bool {
+	return !f.router.accept(f.writer, string(event.Logger()), event.LevelInt())
+}
+
+// routedWriter outputs the logs routed to it with the filter.
+type routedWriter struct {
+	slago.Writer
+	filter slago.Filter
+}
+
+func (w *routedWriter) Filter() slago.Filter {
+	return w.filter
+}
+
+func (w *routedWriter) Start() {
+	if lc, ok := w.Writer.(slago.Lifecycle); ok {
+		lc.Start()
+	}
+}
+
+func (w *routedWriter) Stop() {
+	if lc, ok := w.Writer.(slago.Lifecycle); ok {
+		lc.Stop()
+	}
+}
+
+// isolatedWriter writes raw logs into its own MultiWriter.
+type isolatedWriter struct {
+	*slago.MultiWriter
+}
+
+func (*isolatedWriter) Encoder() slago.Encoder {
+	return nil
+}
+
+func (*isolatedWriter) Filter() slago.Filter {
+	return nil
+}
+
+// Start does nothing, the writer has been started when added into MultiWriter.
+func (*isolatedWriter) Start() {
+}
+
+func (w *isolatedWriter) Stop() {
+	w.Reset()
+}
+
+// loggerName normalizes the name of logger, root is named empty.
+func loggerName(name string) string {
+	name = strings.ToLower(strings.Trim(name, "/"))
+	if name == rootLoggerName {
+		return ""
+	}
+
+	return name
+}
+
+// parentLogger returns the name of parent logger, e.g. github.com/acme for
+// github.com/acme/db, and empty for root.
+func parentLogger(name string) string {
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		return name[:index]
+	}
+
+	return ""
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/coolerfall/slago"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type bufferWriter struct {
+	bytes.Buffer
+	encoder slago.Encoder
+}
+
+func (w *bufferWriter) Encoder() slago.Encoder {
+	return w.encoder
+}
+
+func (w *bufferWriter) Filter() slago.Filter {
+	return nil
+}
+
+func TestLoggerRouter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "logger router test")
+}
+
+var _ = Describe("logger router", func() {
+	notAdditive := false
+	config := &LoggingProperty{
+		Level: "debug",
+		Levels: map[string]string{
+			"root":               "info",
+			"github.com/acme/db": "warn",
+			"github.com/acme":    "debug",
+		},
+		Loggers: []LoggerProperty{
+			{Name: "github.com/acme/db", Writers: []string{"db"}, Additive: &notAdditive},
+			{Name: "github.com/acme", Writers: []string{"acme"}},
+		},
+		Writers: []WriterProperty{{Name: "console"}, {Name: "db"}, {Name: "acme"}},
+	}
+
+	It("inherit level and writers", func() {
+		router := newLoggerRouter(config)
+		Expect(router.minLevel()).To(Equal(slago.DebugLevel))
+
+		root := router.resolve("")
+		Expect(root.level).To(Equal(slago.InfoLevel))
+		Expect(root.writers).To(Equal(map[string]bool{"console": true}))
+		Expect(router.resolve("ROOT")).To(Equal(root))
+
+		api := router.resolve("github.com/acme/api")
+		Expect(api.level).To(Equal(slago.DebugLevel))
+		Expect(api.writers).To(Equal(map[string]bool{"acme": true, "console": true}))
+
+		sql := router.resolve("github.com/acme/db/sql")
+		Expect(sql.level).To(Equal(slago.WarnLevel))
+		Expect(sql.writers).To(Equal(map[string]bool{"db": true}))
+
+		other := router.resolve("github.com/other")
+		Expect(other.level).To(Equal(slago.InfoLevel))
+	})
+	It("route with filter", func() {
+		router := newLoggerRouter(config)
+		db := &bufferWriter{}
+		console := &bufferWriter{}
+		mw := slago.NewMultiWriter()
+		// the filtered logs of one writer should not stop the others
+		mw.AddWriter(isolate(router.route("db", db)), isolate(router.route("console", console)))
+		Expect(router.route("db", db).Filter()).To(BeAssignableToTypeOf(&routeFilter{}))
+
+		debug := []byte(`{"level":"debug","logger_name":"github.com/acme/db","message":"a"}` + "\n")
+		warn := []byte(`{"level":"warn","logger_name":"github.com/acme/db","message":"b"}` + "\n")
+		root := []byte(`{"level":"error","logger_name":"github.com/other","message":"c"}` + "\n")
+		for _, p := range [][]byte{debug, warn, root} {
+			_, err := mw.Write(p)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(db.String()).To(Equal(string(warn)))
+		Expect(console.String()).To(Equal(string(root)))
+	})
+})
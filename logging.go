@@ -16,7 +16,7 @@ package piper
 
 import (
 	"errors"
-	"strings"
+	"fmt"
 	"sync"
 
 	"github.com/coolerfall/slago"
@@ -31,31 +31,53 @@ type loggingSystem struct {
 	initialized bool
 }
 
-// LoggingProperty defines the property of piper.logging section in yaml config.
+func init() {
+	Wire(&LoggingProperty{
+		Level: slago.DebugLevel.String(),
+	})
+}
+
+// LoggingProperty defines the property of logging section in yaml config. The loggers
+// are named by package path, e.g. github.com/acme/db, and the logger without level
+// inherits the level of its nearest parent, e.g. github.com/acme, or root.
 type LoggingProperty struct {
-	Level   string           `piper:"level"`
-	Writers []WriterProperty `piper:"writers"`
+	Level   string            `piper:"level" desc:"the level of root logger, e.g. info"`
+	Levels  map[string]string `piper:"levels" desc:"the levels of named loggers, e.g. github.com/acme/db: warn, root overrides level"`
+	Loggers []LoggerProperty  `piper:"loggers" desc:"the writers attached to named loggers"`
+	Writers []WriterProperty  `piper:"writers" desc:"the writers to output logs"`
+}
+
+func (*LoggingProperty) Prefix() string {
+	return "logging"
+}
+
+// LoggerProperty defines the writers of a named logger. The writers not attached to
+// any logger are attached to root, unless root is configured here.
+type LoggerProperty struct {
+	Name     string   `piper:"name" validate:"required" desc:"the name of logger, e.g. github.com/acme/db or root"`
+	Writers  []string `piper:"writers" desc:"the names of writers attached to the logger"`
+	Additive *bool    `piper:"additive" desc:"output to the writers of parent loggers too, default is true"`
 }
 
 type WriterProperty struct {
-	Name          string                 `piper:"name"`
-	RefWriter     string                 `piper:"ref-writer"`
-	Type          string                 `piper:"type"`
-	Filename      string                 `piper:"filename"`
-	Encoder       *EncoderProperty       `piper:"encoder"`
-	RollingPolicy *RollingPolicyProperty `piper:"rolling-policy"`
+	Name          string                 `piper:"name" desc:"the name of writer"`
+	RefWriter     string                 `piper:"ref-writer" desc:"the writer name referred by async writer"`
+	Type          string                 `piper:"type" validate:"oneof=console file async" desc:"the type of writer"`
+	Filename      string                 `piper:"filename" desc:"the filename of file writer"`
+	Encoder       *EncoderProperty       `piper:"encoder" desc:"the encoder of writer"`
+	RollingPolicy *RollingPolicyProperty `piper:"rolling-policy" desc:"the rolling policy of file writer"`
 }
 
 type EncoderProperty struct {
-	Type   string `piper:"type"`
-	Layout string `piper:"layout"`
+	Type   string `piper:"type" validate:"oneof=json pattern" desc:"the type of encoder"`
+	Layout string `piper:"layout" desc:"the layout of pattern encoder"`
 }
 
 type RollingPolicyProperty struct {
-	Type            string `piper:"type"`
-	FilenamePattern string `piper:"filename-pattern"`
-	MaxSize         string `piper:"max-size"`
-	MaxHistory      int    `piper:"max-history"`
+	Type            string   `piper:"type" validate:"oneof=size-and-time-based time-based" desc:"the type of rolling policy"`
+	FilenamePattern string   `piper:"filename-pattern" desc:"the filename pattern of archived files"`
+	MaxSize         ByteSize `piper:"max-size" validate:"min=0" desc:"the max size of one file, e.g. 10MB"`
+	MaxHistory      int      `piper:"max-history" validate:"min=0" desc:"the max number of archived files to keep"`
 }
 
 // LoggingSystem gets global logging system to configure.
@@ -76,15 +98,17 @@ func (l *loggingSystem) Initialize(env *AppEnv) (err error) {
 	var config = LoggingProperty{
 		Level: slago.DebugLevel.String(),
 	}
-	if err = env.Unmarshal("logging", &config); err != nil {
+	if err = env.Unmarshal(config.Prefix(), &config); err != nil {
 		return err
 	}
 
-	level := slago.ParseLevel(strings.ToUpper(config.Level))
-	slago.Logger().SetLevel(level)
+	router := newLoggerRouter(&config)
+	// the level of each logger is checked by writers, so root logs all the levels
+	slago.Logger().SetLevel(router.minLevel())
 
 	writers := make(map[string]slago.Writer)
-	refWriters := make([]string, 0)
+	// the names of async writers to their ref writers
+	refWriters := make(map[string]string)
 
 	// config logging writters
 	for _, w := range config.Writers {
@@ -96,7 +120,7 @@ func (l *loggingSystem) Initialize(env *AppEnv) (err error) {
 		case "file":
 			writer, err = l.makeFileWriter(w)
 		case "async":
-			refWriters = append(refWriters, w.RefWriter)
+			refWriters[w.Name] = w.RefWriter
 			continue
 		default:
 			return errors.New("unkown slago writer")
@@ -109,21 +133,21 @@ func (l *loggingSystem) Initialize(env *AppEnv) (err error) {
 	}
 
 	// add asynchronous writers
-	for _, name := range refWriters {
-		writer, ok := writers[name]
+	for name, ref := range refWriters {
+		writer, ok := writers[ref]
 		if !ok {
-			return errors.New("no ref writer found: " + name)
+			return errors.New("no ref writer found: " + ref)
 		}
-		delete(writers, name)
+		delete(writers, ref)
 
 		slago.Logger().AddWriter(slago.NewAsyncWriter(func(o *slago.AsyncWriterOption) {
-			o.Ref = writer
+			o.Ref = router.route(name, writer)
 		}))
 	}
 
 	// add other writers
-	for _, w := range writers {
-		slago.Logger().AddWriter(w)
+	for name, w := range writers {
+		slago.Logger().AddWriter(isolate(router.route(name, w)))
 	}
 
 	l.initialized = true
@@ -164,7 +188,9 @@ func (l *loggingSystem) makeFileWriter(wp WriterProperty) (slago.Writer, error)
 			rollingPolicy = slago.NewSizeAndTimeBasedRollingPolicy(
 				func(o *slago.SizeAndTimeBasedRPOption) {
 					o.FilenamePattern = policy.FilenamePattern
-					o.MaxFileSize = policy.MaxSize
+					if policy.MaxSize > 0 {
+						o.MaxFileSize = rollingFileSize(policy.MaxSize)
+					}
 					o.MaxHistory = policy.MaxHistory
 				})
 		case "time-based":
@@ -204,3 +230,9 @@ func (l *loggingSystem) makeEncoder(ep *EncoderProperty) (slago.Encoder, error)
 
 	return encoder, nil
 }
+
+// rollingFileSize converts size into the file size of slago. slago only supports kb, mb
+// and gb for file size, so the size is rounded up to whole KB and never be 0KB.
+func rollingFileSize(size ByteSize) string {
+	return fmt.Sprintf("%dKB", (size+KiloByte-1)/KiloByte)
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLogging(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "logging test")
+}
+
+var _ = Describe("logging", func() {
+	It("rolling file size", func() {
+		Expect(rollingFileSize(1)).To(Equal("1KB"))
+		Expect(rollingFileSize(KiloByte)).To(Equal("1KB"))
+		Expect(rollingFileSize(KiloByte + 1)).To(Equal("2KB"))
+		Expect(rollingFileSize(10 * MegaByte)).To(Equal("10240KB"))
+	})
+})
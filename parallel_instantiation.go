@@ -0,0 +1,162 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func init() {
+	Wire(&InstantiationProperty{})
+}
+
+// InstantiationProperty defines the property of piper.instantiation section in yaml
+// config.
+type InstantiationProperty struct {
+	Parallel bool `piper:"parallel" desc:"instantiate all the providers concurrently when starting, instead of on first retrieval"`
+	Workers  int  `piper:"workers" desc:"the max number of providers instantiated at the same time, defaults to GOMAXPROCS" validate:"min=0"`
+}
+
+func (*InstantiationProperty) Prefix() string {
+	return "piper.instantiation"
+}
+
+// InstantiationError represents the error when a provider panicked while instantiating.
+type InstantiationError struct {
+	// Provider is the name of provider which panicked.
+	Provider string
+	// Err is the error recovered from provider.
+	Err error
+}
+
+func (e *InstantiationError) Error() string {
+	return fmt.Sprintf("instantiate %s error: %v", e.Provider, e.Err)
+}
+
+func (e *InstantiationError) Unwrap() error {
+	return e.Err
+}
+
+// instantiateParallel instantiates all the active nodes in topological order, and
+// the nodes whose dependencies have been instantiated are instantiated concurrently
+// with at most workers goroutines. Each node is scheduled only once, so it will be
+// instantiated exactly once. If any node panics, the nodes not started will be
+// cancelled and the error will be returned after the running ones finished.
+func (c *depTree) instantiateParallel(workers int) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// count the dependencies not instantiated for each node
+	pending := make(map[*graphNode]int)
+	dependents := make(map[*graphNode][]*graphNode)
+	var visit func(node *graphNode)
+	visit = func(node *graphNode) {
+		if _, ok := pending[node]; ok || node.isInstantiated() || !node.resolved {
+			return
+		}
+		pending[node] = 0
+		for _, dep := range node.dependencies {
+			visit(dep)
+			if !dep.isInstantiated() {
+				pending[node]++
+				dependents[dep] = append(dependents[dep], node)
+			}
+		}
+	}
+	// the dependencies of active nodes are active, so the inactive ones are never visited
+	for _, node := range c.nodes() {
+		if c.active(node) {
+			visit(node)
+		}
+	}
+
+	ready := make([]*graphNode, 0)
+	for node, n := range pending {
+		if n == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	type result struct {
+		node *graphNode
+		err  error
+	}
+	jobs := make(chan *graphNode)
+	results := make(chan result)
+	for i := 0; i < workers; i++ {
+		go func() {
+			id := goroutineId()
+			for node := range jobs {
+				results <- result{node: node, err: c.safeBuild(id, node)}
+			}
+		}()
+	}
+	defer close(jobs)
+
+	var err error
+	running := 0
+	for len(ready) != 0 || running != 0 {
+		// stop scheduling once failed, and wait for the running ones
+		var next *graphNode
+		var send chan *graphNode
+		if err == nil && len(ready) != 0 {
+			next = ready[len(ready)-1]
+			send = jobs
+		} else if running == 0 {
+			break
+		}
+
+		select {
+		case send <- next:
+			ready = ready[:len(ready)-1]
+			running++
+		case r := <-results:
+			running--
+			if r.err != nil {
+				if err == nil {
+					err = r.err
+				}
+				continue
+			}
+			for _, dependent := range dependents[r.node] {
+				pending[dependent]--
+				if pending[dependent] == 0 {
+					ready = append(ready, dependent)
+				}
+			}
+		}
+	}
+
+	return err
+}
+
+// safeBuild instantiates the node in the goroutine with id, and recovers the panic
+// as error.
+func (c *depTree) safeBuild(id int64, node *graphNode) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				e = fmt.Errorf("%v", r)
+			}
+			err = &InstantiationError{Provider: node.name, Err: e}
+		}
+	}()
+	c.instantiateIn(id, node)
+
+	return nil
+}
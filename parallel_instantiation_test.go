@@ -0,0 +1,133 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type parallelPool struct {
+}
+
+type parallelCache struct {
+}
+
+type parallelService struct {
+}
+
+type parallelProdClient struct {
+}
+
+var (
+	// parallelBarrier is released when both pool and cache are being instantiated
+	parallelBarrier sync.WaitGroup
+	parallelCalls   int32
+	prodClientCalls int32
+)
+
+func waitParallelBarrier() {
+	parallelBarrier.Done()
+	done := make(chan struct{})
+	go func() {
+		parallelBarrier.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		panic("not instantiated concurrently")
+	}
+}
+
+func newParallelPool() *parallelPool {
+	atomic.AddInt32(&parallelCalls, 1)
+	waitParallelBarrier()
+	return &parallelPool{}
+}
+
+func newParallelCache() *parallelCache {
+	atomic.AddInt32(&parallelCalls, 1)
+	waitParallelBarrier()
+	return &parallelCache{}
+}
+
+func newPanicCache() *parallelCache {
+	panic(errors.New("dial failed"))
+}
+
+func newParallelService(_ *parallelPool, _ *parallelCache) *parallelService {
+	atomic.AddInt32(&parallelCalls, 1)
+	return &parallelService{}
+}
+
+func newParallelProdClient() *parallelProdClient {
+	atomic.AddInt32(&prodClientCalls, 1)
+	return &parallelProdClient{}
+}
+
+func TestParallelInstantiation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "parallel instantiation test")
+}
+
+var _ = Describe("parallel instantiation", func() {
+	It("instantiate independent providers concurrently", func() {
+		atomic.StoreInt32(&parallelCalls, 0)
+		parallelBarrier.Add(2)
+		tree := newDepTree()
+		tree.wire(newParallelService, newParallelPool, newParallelCache)
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		Expect(tree.instantiateParallel(2)).To(Succeed())
+		Expect(atomic.LoadInt32(&parallelCalls)).To(Equal(int32(3)))
+		for _, node := range tree.nodes() {
+			Expect(node.isInstantiated()).To(BeTrue())
+		}
+	})
+	It("skip inactive providers", func() {
+		atomic.StoreInt32(&prodClientCalls, 0)
+		tree := newDepTree()
+		tree.profile = "dev"
+		tree.wire(newParallelService, &parallelPool{}, &parallelCache{})
+		tree.wireWithOption(newParallelProdClient, Active("prod"))
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		Expect(tree.instantiateParallel(0)).To(Succeed())
+		Expect(atomic.LoadInt32(&prodClientCalls)).To(BeZero())
+		for _, node := range tree.nodes() {
+			Expect(node.isInstantiated()).To(Equal(tree.active(node)))
+		}
+	})
+	It("cancel on panic", func() {
+		atomic.StoreInt32(&parallelCalls, 0)
+		tree := newDepTree()
+		tree.wire(newParallelService, newPanicCache, &parallelPool{})
+		Expect(tree.resolveDependencies()).To(Succeed())
+
+		err := tree.instantiateParallel(0)
+		var e *InstantiationError
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Provider).To(HaveSuffix("piper.newPanicCache"))
+		Expect(e.Err).To(MatchError("dial failed"))
+		Expect(atomic.LoadInt32(&parallelCalls)).To(BeZero())
+	})
+})
@@ -16,6 +16,10 @@ package piper
 
 import (
 	"embed"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
 )
 
 // Piper defines a piper application.
@@ -26,9 +30,14 @@ type Piper struct {
 type Option struct {
 	ShowVersion bool
 	Description string
-	Banner      Banner
-	EngineFunc  EngineFunc
-	ResourceFs  embed.FS
+	// Banner is printed when starting, it defaults to the banner.txt in resources.
+	Banner Banner
+	// EngineFunc creates the engine to serve after started. The application stops after
+	// all the ApplicationRunner run if it is nil, which is useful for one-shot jobs.
+	EngineFunc EngineFunc
+	// ResourceFs is the embedded resources, the config files and banner.txt in resources
+	// directory of it are read if not found in file system.
+	ResourceFs embed.FS
 }
 
 func init() {
@@ -43,8 +52,9 @@ func NewPiper(options ...func(*Option)) *Piper {
 		f(opt)
 	}
 
-	cli := newCmdLine(newAppEnv(), CheckNotNil(opt.EngineFunc, "EngineFunc is nil"),
-		CheckNotEmpty(opt.Description))
+	env := newAppEnv()
+	env.resourceFs = newResourceFs(opt.ResourceFs)
+	cli := newCmdLine(env, opt.EngineFunc, CheckNotEmpty(opt.Description))
 	banner := opt.Banner
 	if banner == nil {
 		banner = NewDefaultBanner()
@@ -56,10 +66,26 @@ func NewPiper(options ...func(*Option)) *Piper {
 	}
 }
 
+// AddCommand adds the subcommand to application, e.g. migrate or seed. The run func
+// should be func(*cobra.Command, []string, ...) error, and the parameters after args
+// are resolved from container with the options like a provider after the profile and
+// configuration loaded. The ApplicationRunner and engine will not run for it.
+func (p *Piper) AddCommand(cmd *cobra.Command, run any, opts ...*WireOption) {
+	p.cmdLine.addCommand(cmd, run, opts...)
+}
+
+// Run runs the application, and exits with non-zero code if it failed. The code is
+// taken from the error if it implements ExitCoder, otherwise it is 1.
 func (p *Piper) Run() {
-	p.cmdLine.Execute()
+	if err := p.cmdLine.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
 }
 
+// Execute runs the application and returns the error if it failed. Use errors.Is
+// with ErrWiring or ErrConfig to tell what kind of failure it is, or errors.As with
+// the exported error types to inspect the details.
 func (p *Piper) Execute() error {
 	return p.cmdLine.Execute()
 }
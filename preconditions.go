@@ -29,7 +29,7 @@ func CheckNotEmpty(reference string) string {
 
 // CheckNotNil check if the reference is nil.
 func CheckNotNil[T any](reference T, msg string) T {
-	if reference == nil {
+	if any(reference) == nil {
 		panic(msg)
 	}
 
@@ -0,0 +1,208 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// propertyMu guards the values of properties, the properties are re-bound in place
+// with it locked when configuration reloaded.
+var propertyMu sync.RWMutex
+
+// Snapshot returns a copy of property read with the lock held by re-binding, so it is
+// safe to be called when configuration may be reloaded. Reading the fields of a
+// property directly races with reloading, so the properties which can be hot-reloaded
+// should always be read with Snapshot, e.g. piper.Snapshot(prop).Timeout.
+func Snapshot[T any](property *T) T {
+	propertyMu.RLock()
+	defer propertyMu.RUnlock()
+
+	return *property
+}
+
+// propertyBinder binds configuration into all wired ConfigProperty. The values of
+// properties before the first binding are treated as default values, so properties
+// can be re-bound from these default values when the configuration was reloaded.
+type propertyBinder struct {
+	mu       sync.Mutex
+	defaults map[ConfigProperty]reflect.Value
+}
+
+// stagedProperty holds a bound value which has not been applied to the property yet.
+type stagedProperty struct {
+	property ConfigProperty
+	value    reflect.Value
+}
+
+func newPropertyBinder() *propertyBinder {
+	return &propertyBinder{
+		defaults: make(map[ConfigProperty]reflect.Value),
+	}
+}
+
+// bind binds configuration in env into the given properties.
+func (b *propertyBinder) bind(env *AppEnv, props []ConfigProperty) error {
+	staged, err := b.stage(env, props)
+	if err != nil {
+		return err
+	}
+	b.commit(staged)
+
+	return nil
+}
+
+// stage unmarshals configuration in env into copies of the given properties and
+// validates them. All the violations of properties will be reported together. The
+// properties will not be changed until the staged values are committed.
+func (b *propertyBinder) stage(env *AppEnv, props []ConfigProperty) ([]*stagedProperty, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	staged := make([]*stagedProperty, 0, len(props))
+	violations := make([]*violation, 0)
+	for _, p := range props {
+		pValue := reflect.ValueOf(p)
+		if pValue.Kind() != reflect.Ptr || pValue.Elem().Kind() != reflect.Struct {
+			violations = append(violations, &violation{
+				key: p.Prefix(),
+				msg: fmt.Sprintf("config property should be pointer of struct: %T", p),
+			})
+			continue
+		}
+
+		// configuration is decoded into the existing pointers, slices and maps, so the
+		// defaults and staged value should never share them with the property
+		def, ok := b.defaults[p]
+		if !ok {
+			def = copyValue(pValue.Elem())
+			b.defaults[p] = def
+		}
+
+		value := reflect.New(def.Type())
+		value.Elem().Set(copyValue(def))
+		if err := env.Unmarshal(p.Prefix(), value.Interface()); err != nil {
+			violations = append(violations, &violation{
+				key: p.Prefix(),
+				msg: fmt.Sprintf("bind config property %T error: %v", p, err),
+			})
+			continue
+		}
+		violations = append(violations, validateProperty(p.Prefix(), value.Interface())...)
+
+		staged = append(staged, &stagedProperty{
+			property: p,
+			value:    value.Elem(),
+		})
+	}
+
+	if len(violations) != 0 {
+		return nil, newPropertyValidationError(violations)
+	}
+
+	return staged, nil
+}
+
+// commit applies staged values into properties and returns the previous values
+// which can be committed again to roll back.
+func (b *propertyBinder) commit(staged []*stagedProperty) []*stagedProperty {
+	propertyMu.Lock()
+	defer propertyMu.Unlock()
+
+	previous := make([]*stagedProperty, 0, len(staged))
+	for _, s := range staged {
+		elem := reflect.ValueOf(s.property).Elem()
+		old := reflect.New(elem.Type()).Elem()
+		old.Set(elem)
+		previous = append(previous, &stagedProperty{
+			property: s.property,
+			value:    old,
+		})
+		elem.Set(s.value)
+	}
+
+	return previous
+}
+
+// copyValue deep copies the pointers, slices, maps and exported fields of structs in
+// value. The pointers of structs without exported fields, such as *time.Location, are
+// shared since they cannot be decoded into.
+func copyValue(value reflect.Value) reflect.Value {
+	result := reflect.New(value.Type()).Elem()
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() || !decodable(value.Type().Elem()) {
+			result.Set(value)
+			break
+		}
+		elem := reflect.New(value.Type().Elem())
+		elem.Elem().Set(copyValue(value.Elem()))
+		result.Set(elem)
+	case reflect.Interface:
+		if !value.IsNil() {
+			result.Set(copyValue(value.Elem()))
+		}
+	case reflect.Slice:
+		if value.IsNil() {
+			break
+		}
+		result.Set(reflect.MakeSlice(value.Type(), value.Len(), value.Cap()))
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(copyValue(value.Index(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			result.Index(i).Set(copyValue(value.Index(i)))
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			break
+		}
+		result.Set(reflect.MakeMapWithSize(value.Type(), value.Len()))
+		iter := value.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+	case reflect.Struct:
+		result.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				result.Field(i).Set(copyValue(value.Field(i)))
+			}
+		}
+	default:
+		result.Set(value)
+	}
+
+	return result
+}
+
+// decodable checks if configuration can be decoded into the type, the struct without
+// exported fields cannot be decoded into.
+func decodable(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const validateTag = "validate"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+)
+
+// PropertyValidator can be implemented by ConfigProperty to validate itself after
+// configuration was bound. It will be invoked after all the tag rules are checked.
+type PropertyValidator interface {
+	// Validate validates the bound property.
+	Validate() error
+}
+
+// validateProperty validates the `validate` tag rules of all the fields in property,
+// and invokes Validate if the property implements PropertyValidator. The supported
+// rules are:
+//
+//  required          the value cannot be zero value, empty string or empty collection
+//  min=n, max=n      the range of numbers, durations (e.g. 1s), byte sizes (e.g. 1MB),
+//                    or the length of strings and collections
+//  oneof=a b c       the value should be one of the given values separated by space
+//  regexp=pattern    the string should match the pattern, this should be the last rule
+//
+// For example:
+//
+//  type DbProperty struct {
+//      Url     string        `piper:"url" validate:"required,regexp=^mysql://"`
+//      Timeout time.Duration `piper:"timeout" validate:"min=1s,max=1m"`
+//  }
+func validateProperty(prefix string, property any) []*violation {
+	violations := validateStruct(prefix, reflect.ValueOf(property).Elem())
+
+	if v, ok := property.(PropertyValidator); ok {
+		if err := v.Validate(); err != nil {
+			violations = append(violations, &violation{
+				key: prefix,
+				msg: err.Error(),
+			})
+		}
+	}
+
+	return violations
+}
+
+func validateStruct(prefix string, value reflect.Value) []*violation {
+	violations := make([]*violation, 0)
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+		structField := valueType.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		name := strings.Split(structField.Tag.Get(piper), ",")[0]
+		if len(name) == 0 {
+			name = structField.Name
+		}
+		key := name
+		if len(prefix) != 0 {
+			key = prefix + "." + name
+		}
+
+		field := value.Field(i)
+		if rules, ok := structField.Tag.Lookup(validateTag); ok {
+			violations = append(violations, validateField(key, field, rules)...)
+		}
+		violations = append(violations, validateNested(key, field)...)
+	}
+
+	return violations
+}
+
+// validateNested validates the fields in nested structs, including the structs in
+// pointers, slices and maps.
+func validateNested(key string, field reflect.Value) []*violation {
+	violations := make([]*violation, 0)
+
+	switch field.Kind() {
+	case reflect.Ptr:
+		if !field.IsNil() && field.Elem().Kind() == reflect.Struct {
+			violations = append(violations, validateStruct(key, field.Elem())...)
+		}
+	case reflect.Struct:
+		violations = append(violations, validateStruct(key, field)...)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			violations = append(violations,
+				validateNested(fmt.Sprintf("%s[%d]", key, i), field.Index(i))...)
+		}
+	case reflect.Map:
+		iter := field.MapRange()
+		for iter.Next() {
+			violations = append(violations,
+				validateNested(fmt.Sprintf("%s.%v", key, iter.Key()), iter.Value())...)
+		}
+	}
+
+	return violations
+}
+
+func validateField(key string, field reflect.Value, rules string) []*violation {
+	violations := make([]*violation, 0)
+
+	for _, r := range parseRules(rules) {
+		if err := checkRule(field, r.name, r.arg); err != nil {
+			violations = append(violations, &violation{
+				key: key,
+				msg: err.Error(),
+			})
+		}
+	}
+
+	return violations
+}
+
+// rule represents one rule in `validate` tag, such as min=1.
+type rule struct {
+	name string
+	arg  string
+}
+
+// parseRules parses rules separated by comma in `validate` tag.
+func parseRules(rules string) []rule {
+	result := make([]rule, 0)
+
+	for len(rules) != 0 {
+		var r string
+		if strings.HasPrefix(rules, "regexp=") {
+			// regexp may contain comma, so it takes the rest of rules
+			r, rules = rules, ""
+		} else if index := strings.Index(rules, ","); index >= 0 {
+			r, rules = rules[:index], rules[index+1:]
+		} else {
+			r, rules = rules, ""
+		}
+
+		name, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+		result = append(result, rule{
+			name: name,
+			arg:  arg,
+		})
+	}
+
+	return result
+}
+
+func checkRule(field reflect.Value, name, arg string) error {
+	if name == "required" {
+		if isEmptyValue(field) {
+			return fmt.Errorf("is required")
+		}
+		return nil
+	}
+
+	// other rules only check the present value
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+
+	switch name {
+	case "min", "max":
+		return checkRange(field, name, arg)
+	case "oneof":
+		if field.Kind() == reflect.String && len(field.String()) == 0 {
+			return nil
+		}
+		actual := fmt.Sprint(field.Interface())
+		options := strings.Fields(arg)
+		for _, o := range options {
+			if o == actual {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of [%s], but got %s", strings.Join(options, ", "), actual)
+	case "regexp":
+		if field.Kind() != reflect.String {
+			return fmt.Errorf("regexp rule can only be used for string")
+		}
+		if len(field.String()) == 0 {
+			return nil
+		}
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return fmt.Errorf("invalid regexp %s: %v", arg, err)
+		}
+		if !re.MatchString(field.String()) {
+			return fmt.Errorf("must match %s, but got %s", arg, field.String())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown validate rule: %s", name)
+	}
+}
+
+func checkRange(field reflect.Value, name, arg string) error {
+	var actual, bound float64
+	var display = arg
+	var err error
+
+	switch {
+	case field.Type() == durationType:
+		var d time.Duration
+		d, err = time.ParseDuration(arg)
+		actual, bound = float64(field.Int()), float64(d)
+	case field.Type() == byteSizeType:
+		var s ByteSize
+		s, err = ParseByteSize(arg)
+		actual, bound = float64(field.Int()), float64(s)
+	case field.CanInt():
+		actual = float64(field.Int())
+		bound, err = strconv.ParseFloat(arg, 64)
+	case field.CanUint():
+		actual = float64(field.Uint())
+		bound, err = strconv.ParseFloat(arg, 64)
+	case field.CanFloat():
+		actual = field.Float()
+		bound, err = strconv.ParseFloat(arg, 64)
+	case field.Kind() == reflect.String || field.Kind() == reflect.Slice ||
+		field.Kind() == reflect.Map || field.Kind() == reflect.Array:
+		actual = float64(field.Len())
+		bound, err = strconv.ParseFloat(arg, 64)
+		display = fmt.Sprintf("%s in length", arg)
+	default:
+		return fmt.Errorf("%s rule cannot be used for %s", name, field.Type())
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid %s rule value %s: %v", name, arg, err)
+	}
+
+	if name == "min" && actual < bound {
+		return fmt.Errorf("must be at least %s, but got %v", display, field.Interface())
+	}
+	if name == "max" && actual > bound {
+		return fmt.Errorf("must be at most %s, but got %v", display, field.Interface())
+	}
+
+	return nil
+}
+
+func isEmptyValue(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String:
+		return len(strings.TrimSpace(field.String())) == 0
+	case reflect.Slice, reflect.Map:
+		return field.Len() == 0
+	default:
+		return field.IsZero()
+	}
+}
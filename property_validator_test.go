@@ -0,0 +1,100 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type validatedPoolProperty struct {
+	MaxSize ByteSize `piper:"max-size" validate:"max=1MB"`
+}
+
+type validatedProperty struct {
+	Url     string                  `piper:"url" validate:"required,regexp=^mysql://[a-z,]+$"`
+	Port    int                     `piper:"port" validate:"min=1,max=65535"`
+	Mode    string                  `piper:"mode" validate:"oneof=master slave"`
+	Timeout time.Duration           `piper:"timeout" validate:"min=1s"`
+	Pools   []validatedPoolProperty `piper:"pools"`
+}
+
+func (*validatedProperty) Prefix() string {
+	return "db"
+}
+
+func (p *validatedProperty) Validate() error {
+	if p.Mode == "slave" && p.Port == 3306 {
+		return errors.New("slave cannot use default port")
+	}
+	return nil
+}
+
+func TestPropertyValidator(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "property validator test")
+}
+
+var _ = Describe("property validator", func() {
+	It("valid", func() {
+		violations := validateProperty("db", &validatedProperty{
+			Url:     "mysql://a,b",
+			Port:    3306,
+			Mode:    "master",
+			Timeout: time.Second,
+			Pools:   []validatedPoolProperty{{MaxSize: KiloByte}},
+		})
+		Expect(violations).To(BeEmpty())
+	})
+	It("all violations", func() {
+		err := newPropertyValidationError(validateProperty("db", &validatedProperty{
+			Port:    0,
+			Mode:    "slave",
+			Timeout: time.Millisecond,
+			Pools:   []validatedPoolProperty{{MaxSize: 2 * MegaByte}},
+		}))
+		Expect(err.Error()).To(Equal("4 config property violation(s) found:" +
+			"\n\tdb.url: is required" +
+			"\n\tdb.port: must be at least 1, but got 0" +
+			"\n\tdb.timeout: must be at least 1s, but got 1ms" +
+			"\n\tdb.pools[0].max-size: must be at most 1MB, but got 2MB"))
+	})
+	It("validate hook", func() {
+		violations := validateProperty("db", &validatedProperty{
+			Url:     "mysql://a",
+			Port:    3306,
+			Mode:    "slave",
+			Timeout: time.Second,
+		})
+		Expect(violations).To(Equal([]*violation{{
+			key: "db",
+			msg: "slave cannot use default port",
+		}}))
+	})
+	It("parse byte size", func() {
+		size, err := ParseByteSize("10MB")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(10 * MegaByte))
+		size, err = ParseByteSize("512")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(512 * Byte))
+		_, err = ParseByteSize("ten")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -19,13 +19,15 @@ import (
 	"fmt"
 )
 
-type providerKey struct {
+// ProviderKey represents the key of providers in container, which consists of the
+// type and the name of provider.
+type ProviderKey struct {
 	name      string
 	alias     string
 	isPointer bool
 }
 
-func (k providerKey) String() string {
+func (k ProviderKey) String() string {
 	buffer := new(bytes.Buffer)
 
 	if k.isPointer {
@@ -38,3 +40,18 @@ func (k providerKey) String() string {
 
 	return buffer.String()
 }
+
+// Name returns the package qualified type name, e.g. github.com/acme/db.Pool.
+func (k ProviderKey) Name() string {
+	return k.name
+}
+
+// Alias returns the name given by wire option.
+func (k ProviderKey) Alias() string {
+	return k.alias
+}
+
+// IsPointer returns true if the type is pointer.
+func (k ProviderKey) IsPointer() bool {
+	return k.isPointer
+}
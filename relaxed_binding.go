@@ -0,0 +1,124 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+// keyStrictBinding is the key to enable strict binding, unknown keys under the prefix
+// of bound properties will be treated as error instead of warning.
+const keyStrictBinding = "piper.config.strict-binding"
+
+// relaxedKey returns the canonical form of key, so kebab case, snake case and camel
+// case keys are treated as the same key, e.g. ref-writer, ref_writer and refWriter.
+func relaxedKey(key string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+}
+
+// relaxedMatch matches key in config map with the name in `piper` tag relaxedly.
+func relaxedMatch(mapKey, fieldName string) bool {
+	return relaxedKey(mapKey) == relaxedKey(fieldName)
+}
+
+// envName returns the SCREAMING_SNAKE environment variable name for the config key,
+// e.g. PIPER_APPLICATION_NAME for piper.application.name.
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// overlayEnv overrides the fields of struct in config with environment variables.
+// Only the fields of nested structs are supported, the elements in slices and maps
+// cannot be overridden with environment variables.
+func overlayEnv(key string, fieldType reflect.Type, input any) any {
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+
+	if fieldType.Kind() != reflect.Struct || fieldType == urlType ||
+		fieldType == locationType {
+		if value, ok := os.LookupEnv(envName(key)); ok {
+			return value
+		}
+		return input
+	}
+
+	var output map[string]any
+	for i := 0; i < fieldType.NumField(); i++ {
+		structField := fieldType.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		name := strings.Split(structField.Tag.Get(piper), ",")[0]
+		if len(name) == 0 {
+			name = structField.Name
+		}
+
+		child, _ := lookupRelaxed(input, name)
+		value := overlayEnv(key+"."+name, structField.Type, child)
+		if value == nil || reflect.DeepEqual(value, child) {
+			continue
+		}
+
+		if output == nil {
+			output = copyConfigMap(input)
+		}
+		for k := range output {
+			if relaxedMatch(k, name) {
+				delete(output, k)
+			}
+		}
+		output[name] = value
+	}
+
+	if output == nil {
+		return input
+	}
+
+	return output
+}
+
+// lookupRelaxed finds the value in config map with relaxed key.
+func lookupRelaxed(input any, name string) (any, bool) {
+	m, ok := input.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if relaxedMatch(k, name) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+func copyConfigMap(input any) map[string]any {
+	output := make(map[string]any)
+	if m, ok := input.(map[string]any); ok {
+		for k, v := range m {
+			output[k] = v
+		}
+	}
+
+	return output
+}
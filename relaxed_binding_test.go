@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRelaxedBinding(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "relaxed binding test")
+}
+
+var _ = Describe("relaxed binding", func() {
+	newLoggingEnv := func(extra map[string]any) *AppEnv {
+		writer := map[string]any{
+			"name":      "async",
+			"refWriter": "file",
+			"rolling_policy": map[string]any{
+				"filenamePattern": "app.log",
+				"MAX_HISTORY":     3,
+			},
+		}
+		for k, v := range extra {
+			writer[k] = v
+		}
+
+		env := newAppEnv()
+		Expect(env.MergeConfigMap(map[string]any{
+			"logging": map[string]any{
+				"writers": []any{writer},
+			},
+		})).To(Succeed())
+		return env
+	}
+
+	It("camel and snake case keys", func() {
+		var prop LoggingProperty
+		Expect(newLoggingEnv(nil).Unmarshal("logging", &prop)).To(Succeed())
+		Expect(prop.Writers).To(HaveLen(1))
+		Expect(prop.Writers[0].RefWriter).To(Equal("file"))
+		Expect(*prop.Writers[0].RollingPolicy).To(Equal(RollingPolicyProperty{
+			FilenamePattern: "app.log",
+			MaxHistory:      3,
+		}))
+	})
+	It("environment variables", func() {
+		_ = os.Setenv("LOGGING_LEVEL", "warn")
+		_ = os.Setenv("PIPER_APPLICATION_NAME", "from-env")
+		defer os.Unsetenv("LOGGING_LEVEL")
+		defer os.Unsetenv("PIPER_APPLICATION_NAME")
+
+		env := newLoggingEnv(nil)
+		var prop LoggingProperty
+		Expect(env.Unmarshal("logging", &prop)).To(Succeed())
+		Expect(prop.Level).To(Equal("warn"))
+
+		app, err := Bind[ApplicationProperty](env, "piper.application")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(app.Name).To(Equal("from-env"))
+	})
+	It("unknown keys in strict mode", func() {
+		env := newLoggingEnv(map[string]any{"unknown-key": 1})
+		var prop LoggingProperty
+		Expect(env.Unmarshal("logging", &prop)).To(Succeed())
+
+		Expect(env.MergeConfigMap(map[string]any{
+			"piper": map[string]any{
+				"config": map[string]any{"strict-binding": true},
+			},
+		})).To(Succeed())
+		Expect(env.Unmarshal("logging", &prop)).To(
+			MatchError("unknown config keys: logging.writers[0].unknown-key"))
+	})
+})
@@ -0,0 +1,296 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coolerfall/slago"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultRemoteTimeout = 5 * time.Second
+
+func init() {
+	Wire(&RemoteConfigProperty{}, newRemoteConfigLoader())
+}
+
+// RemoteConfigProperty defines the property of piper.config.remote section in yaml
+// config. The remote config loader is disabled if url is empty.
+type RemoteConfigProperty struct {
+	Url          string        `piper:"url" desc:"the base url of config server, config will be fetched from {url}/{app-name}/{profile}"`
+	Optional     bool          `piper:"optional" desc:"continue to start if no remote config available"`
+	Timeout      time.Duration `piper:"timeout" desc:"the timeout of each request, default is 5s"`
+	CacheDir     string        `piper:"cache-dir" desc:"the directory to save the last fetched config"`
+	PollInterval time.Duration `piper:"poll-interval" desc:"the interval to poll config server, works when reload is enabled"`
+}
+
+func (*RemoteConfigProperty) Prefix() string {
+	return "piper.config.remote"
+}
+
+// remoteConfig is the config fetched from config server with its etag.
+type remoteConfig struct {
+	ETag   string         `json:"etag"`
+	Config map[string]any `json:"config"`
+}
+
+// remoteConfigLoader loads yaml or json config from config server over http. The
+// response with etag will be cached in memory and on disk, so the config server
+// can respond 304 if nothing changed, and the last config on disk will be used if
+// the config server is not available.
+type remoteConfigLoader struct {
+	mu     sync.Mutex
+	cached map[string]*remoteConfig
+}
+
+func newRemoteConfigLoader() *remoteConfigLoader {
+	return &remoteConfigLoader{
+		cached: make(map[string]*remoteConfig),
+	}
+}
+
+func (l *remoteConfigLoader) Order() int {
+	// remote config overrides the config in application files
+	return math.MinInt32 + 2
+}
+
+func (l *remoteConfigLoader) Load(env *AppEnv) error {
+	prop, err := Bind[RemoteConfigProperty](env, (*RemoteConfigProperty)(nil).Prefix())
+	if err != nil {
+		return err
+	}
+	if len(prop.Url) == 0 {
+		return nil
+	}
+
+	cfg, _, err := l.fetch(&prop, env)
+	if err != nil {
+		if prop.Optional {
+			slago.Logger().Warn().Err(err).Msg("no remote config available, ignored")
+			return nil
+		}
+		return err
+	}
+
+	return env.MergeConfigMap(cfg.Config)
+}
+
+// Watch polls config server with poll interval, and invokes onChange if the config
+// has changed.
+func (l *remoteConfigLoader) Watch(env *AppEnv, onChange func()) func() {
+	prop, err := Bind[RemoteConfigProperty](env, (*RemoteConfigProperty)(nil).Prefix())
+	if err != nil || len(prop.Url) == 0 || prop.PollInterval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(prop.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, changed, err := l.fetch(&prop, env)
+				if err != nil {
+					slago.Logger().Warn().Err(err).Msg("poll remote config error")
+					continue
+				}
+				if changed {
+					onChange()
+				}
+			}
+		}
+	}()
+
+	// stop waits until polling exits, so nothing will be fetched after stopped
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+		<-stopped
+	}
+}
+
+// fetch fetches config from config server, and returns true if the config has changed
+// since last fetch. The cached config will be returned if the server is not available.
+func (l *remoteConfigLoader) fetch(prop *RemoteConfigProperty,
+	env *AppEnv) (*remoteConfig, bool, error) {
+	appName := env.GetString("piper.application.name")
+	profile := env.Profile()
+	if len(profile) == 0 {
+		profile = "default"
+	}
+	cacheKey := appName + "-" + profile
+	cached := l.cachedConfig(prop, cacheKey)
+
+	cfg, err := l.request(prop, appName, profile, cached)
+	if err != nil {
+		if cached == nil {
+			return nil, false, err
+		}
+		slago.Logger().Warn().Err(err).Msg("fetch remote config error, use cached config")
+		return cached, false, nil
+	}
+
+	if cfg == cached {
+		return cached, false, nil
+	}
+
+	l.mu.Lock()
+	l.cached[cacheKey] = cfg
+	l.mu.Unlock()
+	l.saveSnapshot(prop, cacheKey, cfg)
+
+	changed := cached == nil || !reflect.DeepEqual(cached.Config, cfg.Config)
+	return cfg, changed, nil
+}
+
+func (l *remoteConfigLoader) request(prop *RemoteConfigProperty, appName, profile string,
+	cached *remoteConfig) (*remoteConfig, error) {
+	configUrl := strings.TrimRight(prop.Url, "/") + "/" +
+		url.PathEscape(appName) + "/" + url.PathEscape(profile)
+	req, err := http.NewRequest(http.MethodGet, configUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/yaml, application/json")
+	if cached != nil && len(cached.ETag) != 0 {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	timeout := prop.Timeout
+	if timeout <= 0 {
+		timeout = defaultRemoteTimeout
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return nil, fmt.Errorf("remote config %s not modified but no cache found", configUrl)
+		}
+		return cached, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("fetch remote config %s error: %s", configUrl, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := make(map[string]any)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.HasSuffix(mediaType, "json") {
+		err = json.Unmarshal(body, &cfg)
+	} else {
+		err = yaml.Unmarshal(body, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse remote config %s error: %v", configUrl, err)
+	}
+
+	return &remoteConfig{
+		ETag:   resp.Header.Get("ETag"),
+		Config: cfg,
+	}, nil
+}
+
+// cachedConfig gets cached config in memory, or the snapshot on disk.
+func (l *remoteConfigLoader) cachedConfig(prop *RemoteConfigProperty,
+	cacheKey string) *remoteConfig {
+	l.mu.Lock()
+	cfg, ok := l.cached[cacheKey]
+	l.mu.Unlock()
+	if ok {
+		return cfg
+	}
+
+	filename := l.snapshotFile(prop, cacheKey)
+	if len(filename) == 0 {
+		return nil
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	cfg = &remoteConfig{}
+	if err = json.Unmarshal(content, cfg); err != nil {
+		slago.Logger().Warn().Err(err).Msg("invalid remote config snapshot " + filename)
+		return nil
+	}
+
+	return cfg
+}
+
+func (l *remoteConfigLoader) saveSnapshot(prop *RemoteConfigProperty,
+	cacheKey string, cfg *remoteConfig) {
+	filename := l.snapshotFile(prop, cacheKey)
+	if len(filename) == 0 {
+		return
+	}
+
+	content, err := json.Marshal(cfg)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(filename), 0700)
+	}
+	if err == nil {
+		// write to temp file and rename, so the snapshot is never half written
+		tmp := filename + ".tmp"
+		if err = os.WriteFile(tmp, content, 0600); err == nil {
+			err = os.Rename(tmp, filename)
+		}
+	}
+	if err != nil {
+		slago.Logger().Warn().Err(err).Msg("save remote config snapshot error")
+	}
+}
+
+func (l *remoteConfigLoader) snapshotFile(prop *RemoteConfigProperty, cacheKey string) string {
+	dir := prop.CacheDir
+	if len(dir) == 0 {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(cacheDir, piper, "config")
+	}
+
+	return filepath.Join(dir, cacheKey+".json")
+}
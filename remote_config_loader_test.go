@@ -0,0 +1,123 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRemoteConfigLoader(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "remote config loader test")
+}
+
+var _ = Describe("remote config loader", func() {
+	var (
+		server   *httptest.Server
+		version  int32
+		requests int32
+		cacheDir string
+	)
+
+	newRemoteEnv := func() *AppEnv {
+		env := newAppEnv()
+		Expect(env.MergeConfigMap(map[string]any{
+			"piper": map[string]any{
+				"application": map[string]any{"name": "demo"},
+				"config": map[string]any{
+					"remote": map[string]any{
+						"url":           server.URL,
+						"cache-dir":     cacheDir,
+						"poll-interval": "10ms",
+					},
+				},
+			},
+		})).To(Succeed())
+		return env
+	}
+
+	BeforeEach(func() {
+		var err error
+		cacheDir, err = os.MkdirTemp("", "piper-remote")
+		Expect(err).NotTo(HaveOccurred())
+		atomic.StoreInt32(&version, 1)
+		atomic.StoreInt32(&requests, 0)
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			Expect(r.URL.Path).To(Equal("/demo/default"))
+			etag := fmt.Sprintf(`"v%d"`, atomic.LoadInt32(&version))
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintf(w, `{"db": {"pool": %d}}`, atomic.LoadInt32(&version))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		_ = os.RemoveAll(cacheDir)
+	})
+
+	It("load with etag and fall back to snapshot", func() {
+		env := newRemoteEnv()
+		Expect(newRemoteConfigLoader().Load(env)).To(Succeed())
+		Expect(env.GetInt("db.pool")).To(Equal(1))
+
+		loader := newRemoteConfigLoader()
+		env = newRemoteEnv()
+		Expect(loader.Load(env)).To(Succeed())
+		Expect(env.GetInt("db.pool")).To(Equal(1))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+
+		server.Close()
+		env = newRemoteEnv()
+		Expect(newRemoteConfigLoader().Load(env)).To(Succeed())
+		Expect(env.GetInt("db.pool")).To(Equal(1))
+	})
+	It("poll for updates", func() {
+		loader := newRemoteConfigLoader()
+		env := newRemoteEnv()
+		Expect(loader.Load(env)).To(Succeed())
+
+		changed := make(chan struct{}, 1)
+		stop := loader.Watch(env, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+		defer stop()
+
+		atomic.StoreInt32(&version, 2)
+		Eventually(changed, time.Second).Should(Receive())
+
+		env = newRemoteEnv()
+		Expect(loader.Load(env)).To(Succeed())
+		Expect(env.GetInt("db.pool")).To(Equal(2))
+	})
+})
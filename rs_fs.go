@@ -15,32 +15,51 @@
 package piper
 
 import (
-	"embed"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/spf13/afero"
 )
 
-// resourceFs defines a readonly afero.Fs which is used to read config in resources
-// of application. The os filesystem has high priority to read. The embedded
-// filesystem will be read if os filesystem not found.
+// resourceFs defines a readonly afero.Fs which is used to read config and other files
+// in resources of application. The os filesystem has high priority to read. The
+// embedded resources are mounted at /piper/resources, which is the last config search
+// path, and will be read if os filesystem not found.
 type resourceFs struct {
-	osFs  afero.Fs
-	memFs afero.Fs
+	osFs    afero.Fs
+	embedFs afero.Fs
 }
 
-// memFs will be used to store embedded resources
-var memFs = afero.NewMemMapFs()
+// newResourceFs creates a new instance resourceFs with embedded resources, the files
+// in resources directory of it will be mounted.
+func newResourceFs(resources fs.FS) afero.Fs {
+	rsFs := &resourceFs{
+		osFs: afero.NewReadOnlyFs(afero.NewOsFs()),
+	}
+	if resources != nil {
+		rsFs.embedFs = afero.NewReadOnlyFs(afero.FromIOFS{FS: resources})
+	}
+
+	return rsFs
+}
 
-// newResourceFs creates a new instance resourceFs.
-func newResourceFs(embed embed.FS) afero.Fs {
-	return &resourceFs{
-		osFs:  afero.NewOsFs(),
-		memFs: afero.NewReadOnlyFs(memFs),
+// embeddedName converts the name in /piper/resources into the name in embedded
+// resources, it returns false if the name is not in embedded resources.
+func (fs *resourceFs) embeddedName(name string) (string, bool) {
+	if fs.embedFs == nil {
+		return "", false
+	}
+	name = filepath.ToSlash(filepath.Clean(name))
+	mount := "/" + piper + "/" + resourcesDir
+	if name != mount && !strings.HasPrefix(name, mount+"/") {
+		return "", false
 	}
 
+	return strings.TrimPrefix(name, "/"+piper+"/"), true
 }
 
 func (*resourceFs) Create(_ string) (afero.File, error) {
@@ -60,8 +79,11 @@ func (fs *resourceFs) Open(name string) (afero.File, error) {
 	if err == nil {
 		return file, nil
 	}
+	if embedded, ok := fs.embeddedName(name); ok {
+		return fs.embedFs.Open(embedded)
+	}
 
-	return fs.memFs.Open(name)
+	return nil, err
 }
 
 func (fs *resourceFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
@@ -69,8 +91,11 @@ func (fs *resourceFs) OpenFile(name string, flag int, perm os.FileMode) (afero.F
 	if err == nil {
 		return file, nil
 	}
+	if embedded, ok := fs.embeddedName(name); ok {
+		return fs.embedFs.OpenFile(embedded, flag, perm)
+	}
 
-	return fs.memFs.OpenFile(name, flag, perm)
+	return nil, err
 }
 
 func (*resourceFs) Remove(_ string) error {
@@ -90,8 +115,11 @@ func (fs *resourceFs) Stat(name string) (os.FileInfo, error) {
 	if err == nil {
 		return fileInfo, nil
 	}
+	if embedded, ok := fs.embeddedName(name); ok {
+		return fs.embedFs.Stat(embedded)
+	}
 
-	return fs.memFs.Stat(name)
+	return nil, err
 }
 
 func (*resourceFs) Name() string {
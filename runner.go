@@ -0,0 +1,76 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ApplicationRunner runs once after the application started, which can be used for
+// batch jobs or migrations. The runners run in the order of Ordered, and the rest of
+// them will not run once one of them failed.
+type ApplicationRunner interface {
+	// Run runs with the command line arguments left after the flags of start command.
+	Run(env *AppEnv, args []string) error
+}
+
+// ExitCoder can be implemented by the error returned from ApplicationRunner to set the
+// exit code of process, otherwise the process exits with 1 when failed.
+type ExitCoder interface {
+	// ExitCode returns the code to exit the process with.
+	ExitCode() int
+}
+
+// RunnerError represents the error returned by ApplicationRunner.
+type RunnerError struct {
+	// Runner is the type of runner.
+	Runner string
+	// Err is the error returned from runner.
+	Err error
+}
+
+func (e *RunnerError) Error() string {
+	return fmt.Sprintf("run %s error: %v", e.Runner, e.Err)
+}
+
+func (e *RunnerError) Unwrap() error {
+	return e.Err
+}
+
+// runApplicationRunners runs all the runners in order until one of them failed.
+func runApplicationRunners(tree *depTree, env *AppEnv, args []string) error {
+	for _, v := range tree.retrieve(reflect.TypeOf((*ApplicationRunner)(nil))) {
+		if err := v.(ApplicationRunner).Run(env, args); err != nil {
+			return &RunnerError{Runner: fmt.Sprintf("%T", v), Err: err}
+		}
+	}
+
+	return nil
+}
+
+// exitCode returns the exit code of process for the error.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+
+	return 1
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var runnerCalls []string
+
+type migrateRunner struct {
+}
+
+func (*migrateRunner) Order() int {
+	return HighestOrder
+}
+
+func (*migrateRunner) Run(_ *AppEnv, args []string) error {
+	runnerCalls = append(runnerCalls, "migrate")
+	if len(args) != 0 && args[0] == "fail" {
+		return exitError(3)
+	}
+	return nil
+}
+
+type jobRunner struct {
+}
+
+func (*jobRunner) Run(_ *AppEnv, args []string) error {
+	runnerCalls = append(runnerCalls, fmt.Sprintf("job %v", args))
+	return nil
+}
+
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit with %d", int(e))
+}
+
+func (e exitError) ExitCode() int {
+	return int(e)
+}
+
+func TestRunner(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "runner test")
+}
+
+var _ = Describe("application runner", func() {
+	var tree *depTree
+
+	BeforeEach(func() {
+		runnerCalls = nil
+		tree = newDepTree()
+		tree.wire(&jobRunner{}, &migrateRunner{})
+		Expect(tree.resolveDependencies()).To(Succeed())
+	})
+
+	It("run in order with args", func() {
+		Expect(runApplicationRunners(tree, nil, []string{"a"})).To(Succeed())
+		Expect(runnerCalls).To(Equal([]string{"migrate", "job [a]"}))
+	})
+	It("stop at the first error", func() {
+		err := runApplicationRunners(tree, nil, []string{"fail"})
+		Expect(runnerCalls).To(Equal([]string{"migrate"}))
+
+		var e *RunnerError
+		Expect(errors.As(err, &e)).To(BeTrue())
+		Expect(e.Runner).To(Equal("*piper.migrateRunner"))
+		Expect(exitCode(err)).To(Equal(3))
+	})
+	It("exit code", func() {
+		Expect(exitCode(nil)).To(BeZero())
+		Expect(exitCode(errors.New("failed"))).To(Equal(1))
+	})
+})
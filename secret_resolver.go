@@ -0,0 +1,203 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const (
+	fileSecretPrefix      = "secret:file:"
+	encryptedSecretPrefix = "enc:"
+
+	// EnvSecretKey is the environment variable of base64 encoded AES key to decrypt
+	// `enc:` values in configuration. The key can be generated with:
+	//
+	//  openssl rand -base64 32
+	EnvSecretKey = "PIPER_SECRET_KEY"
+	// EnvSecretKeyFile is the environment variable of the file path which contains the
+	// base64 encoded AES key. This will be used if PIPER_SECRET_KEY is not set.
+	EnvSecretKeyFile = "PIPER_SECRET_KEY_FILE"
+)
+
+// SecretResolver resolves secret references in configuration values, such as
+// `secret:file:/run/secrets/db_pass`. The wired resolvers will be tried in order when
+// configuration is merged into AppEnv. Note that secret resolvers will be retrieved
+// before configuration properties are bound, so they should not depend on any
+// configuration properties.
+type SecretResolver interface {
+	// Resolve resolves the given value. It returns false if the value is not a
+	// reference which can be handled by this resolver.
+	Resolve(value string) (string, bool, error)
+}
+
+func init() {
+	Wire(&fileSecretResolver{}, &encryptedSecretResolver{})
+}
+
+// resolveSecret resolves value with the first resolver which can handle it.
+func resolveSecret(value string, resolvers []SecretResolver) (string, error) {
+	for _, r := range resolvers {
+		resolved, ok, err := r.Resolve(value)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return resolved, nil
+		}
+	}
+
+	return value, nil
+}
+
+// retrieveSecretResolvers retrieves all wired secret resolvers.
+func retrieveSecretResolvers() []SecretResolver {
+	return Retrieve[SecretResolver](reflect.TypeOf((*SecretResolver)(nil)))
+}
+
+// fileSecretResolver reads the secret from file with `secret:file:` prefix, this is
+// useful for docker or kubernetes secrets. The plain `file:` values such as sqlite dsn
+// are not secrets and will be kept as is. The trailing new line in file will be trimmed.
+type fileSecretResolver struct {
+}
+
+func (*fileSecretResolver) Order() int {
+	return LowestOrder
+}
+
+func (*fileSecretResolver) Resolve(value string) (string, bool, error) {
+	if !strings.HasPrefix(value, fileSecretPrefix) {
+		return "", false, nil
+	}
+
+	filename := strings.TrimPrefix(value, fileSecretPrefix)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", false, fmt.Errorf("read secret file error: %v", err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), true, nil
+}
+
+// encryptedSecretResolver decrypts the secret with `enc:` prefix with AES-GCM. The
+// encrypted value can be generated with `config encrypt` command.
+type encryptedSecretResolver struct {
+}
+
+func (*encryptedSecretResolver) Order() int {
+	return LowestOrder
+}
+
+func (*encryptedSecretResolver) Resolve(value string) (string, bool, error) {
+	if !strings.HasPrefix(value, encryptedSecretPrefix) {
+		return "", false, nil
+	}
+
+	key, err := secretKey()
+	if err != nil {
+		return "", false, err
+	}
+
+	plain, err := decryptSecret(key, strings.TrimPrefix(value, encryptedSecretPrefix))
+	if err != nil {
+		return "", false, err
+	}
+
+	return plain, true, nil
+}
+
+// secretKey reads the AES key from environment variable or key file.
+func secretKey() ([]byte, error) {
+	encoded := os.Getenv(EnvSecretKey)
+	if len(encoded) == 0 {
+		keyFile := os.Getenv(EnvSecretKeyFile)
+		if len(keyFile) == 0 {
+			return nil, fmt.Errorf("no secret key found, %s or %s should be set",
+				EnvSecretKey, EnvSecretKeyFile)
+		}
+
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read secret key file error: %v", err)
+		}
+		encoded = string(content)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("secret key is not valid base64: %v", err)
+	}
+
+	return key, nil
+}
+
+func newSecretCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptSecret encrypts the plain text with AES-GCM, and returns base64 encoded
+// nonce and cipher text.
+func encryptSecret(key []byte, plain string) (string, error) {
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret decrypts the value encrypted by encryptSecret.
+func decryptSecret(key []byte, encrypted string) (string, error) {
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("encrypted secret is not valid base64: %v", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+
+	nonce, cipherText := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret error: %v", err)
+	}
+
+	return string(plain), nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSecretResolver(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "secret resolver test")
+}
+
+var _ = Describe("secret resolver", func() {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	resolvers := []SecretResolver{&fileSecretResolver{}, &encryptedSecretResolver{}}
+
+	It("encrypt and decrypt", func() {
+		encrypted, err := encryptSecret(key, "s3cret")
+		Expect(err).NotTo(HaveOccurred())
+		plain, err := decryptSecret(key, encrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plain).To(Equal("s3cret"))
+
+		_, err = decryptSecret([]byte("fedcba9876543210fedcba9876543210"), encrypted)
+		Expect(err).To(HaveOccurred())
+	})
+	It("resolve in config", func() {
+		dir, err := os.MkdirTemp("", "piper-secret")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		secretFile := filepath.Join(dir, "db_pass")
+		Expect(os.WriteFile(secretFile, []byte("from-file\n"), 0600)).To(Succeed())
+		encrypted, err := encryptSecret(key, "from-enc")
+		Expect(err).NotTo(HaveOccurred())
+		_ = os.Setenv(EnvSecretKey, base64.StdEncoding.EncodeToString(key))
+		defer os.Unsetenv(EnvSecretKey)
+
+		env := newAppEnv()
+		cfg, err := env.expandMerge(map[string]any{
+			"db": map[string]any{
+				"password": "secret:file:" + secretFile,
+				"token":    "enc:" + encrypted,
+				"user":     "root",
+				"dsn":      "file:test.db?cache=shared",
+				"replicas": []any{"secret:file:" + secretFile, "plain"},
+			},
+		}, true, resolvers)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(Equal(map[string]any{
+			"db": map[string]any{
+				"password": "from-file",
+				"token":    "from-enc",
+				"user":     "root",
+				"dsn":      "file:test.db?cache=shared",
+				"replicas": []any{"from-file", "plain"},
+			},
+		}))
+	})
+	It("missing secret file", func() {
+		env := newAppEnv()
+		_, err := env.expandMerge(map[string]any{
+			"password": "secret:file:/not/exist/secret",
+		}, false, resolvers)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,171 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const defaultSlowestSteps = 10
+
+// the phases of startup steps, engine-create measures creating the engine only, the
+// start of engine is excluded since it blocks until the engine stops
+const (
+	phaseConfig        = "config"
+	phaseConfigLoader  = "config-loader"
+	phaseInitializer   = "initializer"
+	phaseResolve       = "resolve"
+	phaseValidate      = "validate"
+	phaseBind          = "bind"
+	phaseProvider      = "provider"
+	phaseStartListener = "start-listener"
+	phaseEngineCreate  = "engine-create"
+)
+
+var _timeline = &startupTimeline{}
+
+func init() {
+	Wire(&StartupProperty{})
+}
+
+// StartupProperty defines the property of piper.startup section in yaml config.
+type StartupProperty struct {
+	Timing       bool   `piper:"timing" desc:"print the slowest startup steps when application started"`
+	Slowest      int    `piper:"slowest" desc:"the number of slowest steps to print, default is 10" validate:"min=0"`
+	TimelineFile string `piper:"timeline-file" desc:"the file to write the full startup timeline in json"`
+}
+
+func (*StartupProperty) Prefix() string {
+	return "piper.startup"
+}
+
+// StartupStep represents a step measured when application starts, such as a config
+// loader or a provider constructor. The steps of config phase contain the steps of
+// each config loader, and the duration of provider excludes its dependencies.
+type StartupStep struct {
+	Phase    string        `json:"phase"`
+	Name     string        `json:"name,omitempty"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// StartupTimeline returns all the steps measured when application starts in the order
+// they finished.
+func StartupTimeline() []StartupStep {
+	return _timeline.snapshot()
+}
+
+// startupTimeline records the startup steps until the application started. It is safe
+// to be used concurrently, since the providers may be instantiated in parallel.
+type startupTimeline struct {
+	mu       sync.Mutex
+	steps    []StartupStep
+	finished bool
+}
+
+// track starts to measure a step, and the returned func should be called when the step
+// finished. The steps finished after the application started will not be recorded.
+func (t *startupTimeline) track(phase, name string) func() {
+	start := time.Now()
+
+	return func() {
+		step := StartupStep{Phase: phase, Name: name, Start: start,
+			Duration: time.Since(start)}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if !t.finished {
+			t.steps = append(t.steps, step)
+		}
+	}
+}
+
+// finish stops recording and returns all the steps.
+func (t *startupTimeline) finish() []StartupStep {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished = true
+
+	return append([]StartupStep(nil), t.steps...)
+}
+
+// snapshot returns the steps recorded so far.
+func (t *startupTimeline) snapshot() []StartupStep {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]StartupStep(nil), t.steps...)
+}
+
+// reportStartup prints the slowest steps and writes the full timeline if enabled.
+func reportStartup(w io.Writer, prop *StartupProperty, steps []StartupStep) error {
+	if prop.Timing {
+		n := prop.Slowest
+		if n == 0 {
+			n = defaultSlowestSteps
+		}
+		printSlowestSteps(w, steps, n)
+	}
+
+	if prop.TimelineFile == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(steps, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(prop.TimelineFile, content, 0644)
+}
+
+// printSlowestSteps prints the slowest n steps as table.
+func printSlowestSteps(w io.Writer, steps []StartupStep, n int) {
+	var total time.Duration
+	if len(steps) != 0 {
+		first, last := steps[0].Start, steps[0].Start.Add(steps[0].Duration)
+		for _, s := range steps {
+			if s.Start.Before(first) {
+				first = s.Start
+			}
+			if end := s.Start.Add(s.Duration); end.After(last) {
+				last = end
+			}
+		}
+		total = last.Sub(first)
+	}
+
+	slowest := append([]StartupStep(nil), steps...)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].Duration > slowest[j].Duration
+	})
+	if len(slowest) > n {
+		slowest = slowest[:n]
+	}
+
+	_, _ = fmt.Fprintf(w, "Startup took %v, the slowest %d of %d steps:\n", total,
+		len(slowest), len(steps))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PHASE\tNAME\tDURATION")
+	for _, s := range slowest {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%v\n", s.Phase, s.Name, s.Duration)
+	}
+	_ = tw.Flush()
+}
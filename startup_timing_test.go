@@ -0,0 +1,86 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package piper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestStartupTiming(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "startup timing test")
+}
+
+var _ = Describe("startup timing", func() {
+	It("stop recording when finished", func() {
+		timeline := &startupTimeline{}
+		done := timeline.track(phaseConfig, "")
+		timeline.track(phaseProvider, "a.newA")()
+		done()
+		late := timeline.track(phaseProvider, "a.newB")
+
+		steps := timeline.finish()
+		late()
+		Expect(steps).To(HaveLen(2))
+		Expect(steps[0].Name).To(Equal("a.newA"))
+		Expect(steps[1].Phase).To(Equal(phaseConfig))
+		Expect(timeline.snapshot()).To(HaveLen(2))
+	})
+	It("print slowest steps", func() {
+		start := time.Now()
+		steps := []StartupStep{
+			{Phase: phaseResolve, Start: start, Duration: time.Second},
+			{Phase: phaseProvider, Name: "a.newDB", Start: start, Duration: 3 * time.Second},
+			{Phase: phaseProvider, Name: "a.newCache", Start: start, Duration: 2 * time.Second},
+		}
+		out := new(bytes.Buffer)
+		err := reportStartup(out, &StartupProperty{Timing: true, Slowest: 2}, steps)
+		Expect(err).NotTo(HaveOccurred())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(4))
+		Expect(lines[0]).To(Equal("Startup took 3s, the slowest 2 of 3 steps:"))
+		Expect(lines[2]).To(MatchRegexp(`^provider\s+a.newDB\s+3s$`))
+		Expect(lines[3]).To(MatchRegexp(`^provider\s+a.newCache\s+2s$`))
+	})
+	It("write timeline file", func() {
+		dir, err := os.MkdirTemp("", "piper-startup")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "timeline.json")
+
+		steps := []StartupStep{{Phase: phaseEngineCreate, Start: time.Now(), Duration: time.Millisecond}}
+		out := new(bytes.Buffer)
+		Expect(reportStartup(out, &StartupProperty{TimelineFile: file}, steps)).To(Succeed())
+		Expect(out.Len()).To(BeZero())
+
+		content, err := os.ReadFile(file)
+		Expect(err).NotTo(HaveOccurred())
+		var timeline []map[string]any
+		Expect(json.Unmarshal(content, &timeline)).To(Succeed())
+		Expect(timeline).To(HaveLen(1))
+		Expect(timeline[0]["phase"]).To(Equal("engine-create"))
+		Expect(timeline[0]["duration"]).To(BeEquivalentTo(time.Millisecond))
+	})
+})
@@ -0,0 +1,58 @@
+package a
+
+import (
+	"io"
+	"time"
+
+	"github.com/go-piper/piper"
+)
+
+type Repo struct {
+}
+
+type Service struct {
+}
+
+type Callback func()
+
+func newRepo() *Repo {
+	return &Repo{}
+}
+
+func newService(_ *Repo, _ io.Writer, _ time.Duration) *Service {
+	return &Service{}
+}
+
+func newCallbackService(_ Callback, _ func()) *Service {
+	return &Service{}
+}
+
+func newPair() (*Repo, error) {
+	return &Repo{}, nil
+}
+
+func init() {
+	timeout := time.Second
+	names := []string{"a"}
+	opt := piper.Name("repo")
+
+	piper.Wire(newRepo, &Repo{}, []Repo{})
+	piper.WireWithOption(newService, piper.Name("repo"), piper.Default(io.Discard),
+		piper.Default(time.Minute), piper.Out().Primary())
+	piper.WireWithOption(newService, opt, piper.Name("a"), piper.Name("b"), piper.Name("c"))
+
+	piper.WireWithOption(newService, piper.Out(), piper.Name("repo"))       // want `wire out option can only be the last option`
+	piper.WireWithOption(newRepo, piper.Name("repo"))                       // want `wire in option is more than the in parameters in provider`
+	piper.WireWithOption(newService, piper.Primary().Name("a"), piper.In()) // want `wire out option can only be the last option`
+	piper.WireWithOption(newService, piper.In().Primary())                  // want `primary option of wire in parameter cannot exist`
+	piper.WireWithOption(newRepo, piper.Out().Default(1))                   // want `required option of wire out parameter cannot be false`
+	piper.Wire(newCallbackService)                                          // want `closure type func\(\) of parameter 1 cannot be wired`
+	piper.Wire(newPair)                                                     // want `provider should have exactly one out parameter, got 2`
+	piper.Wire(&timeout, names)                                             // want `primitive type \*time.Duration should be provided with` `primitive type \[\]string should be provided with`
+	piper.WireWithOption(&timeout, piper.OutName("timeout"))
+	piper.Wire(Repo{}, nil, piper.Name("a"))                              // want `only non nil pointer type, chan, map, slice can be passed to wire, got a.Repo` `provider cannot be nil` `provider cannot be wire option`
+	piper.WireWithOption(newService, piper.In(), piper.Default("stdout"), // want `default value of type string does not match parameter of type io.Writer`
+		piper.Default(3*time.Second))
+	piper.WireWithOption(newService, piper.In(), piper.In(), piper.Default(int64(3))) // want `default value of type int64 does not match parameter of type time.Duration`
+	piper.WireWithOption(newService, piper.In(), piper.Default(newRepo))              // want `default value cannot be func`
+}
@@ -0,0 +1,31 @@
+// Package piper is the stub of piper for testing.
+package piper
+
+type WireOption struct {
+}
+
+func Wire(providers ...any) {}
+
+func WireWithOption(provider any, opts ...*WireOption) {}
+
+func In() *WireOption { return nil }
+
+func Out() *WireOption { return nil }
+
+func Name(name string) *WireOption { return nil }
+
+func OutName(name string) *WireOption { return nil }
+
+func Default(defVal any) *WireOption { return nil }
+
+func Primary() *WireOption { return nil }
+
+func Active(profiles ...string) *WireOption { return nil }
+
+func (o *WireOption) Name(name string) *WireOption { return o }
+
+func (o *WireOption) Default(defVal any) *WireOption { return o }
+
+func (o *WireOption) Primary() *WireOption { return o }
+
+func (o *WireOption) Active(profiles ...string) *WireOption { return o }
@@ -0,0 +1,210 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wirecheck defines an Analyzer that reports the misuse of piper.Wire and
+// piper.WireWithOption, which would fail when the application starts.
+package wirecheck
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/go-piper/piper/internal/wireopt"
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = `check the misuse of piper.Wire and piper.WireWithOption
+
+The wirecheck analyzer reports the providers and wire options which the container
+will reject when the application starts, such as:
+
+	piper.WireWithOption(newA, piper.Out(), piper.Name("b"))  // out option is not last
+	piper.WireWithOption(newA, piper.Name("a"), piper.Name("b"))  // more in options than parameters
+	piper.Wire(func(cb func()) *A { ... })  // closure type cannot be wired
+	piper.Wire(&timeout)  // primitive type without WireWithOption
+	piper.WireWithOption(newA, piper.Default("a"))  // default value mismatches parameter`
+
+// Analyzer reports the misuse of piper.Wire and piper.WireWithOption.
+var Analyzer = &analysis.Analyzer{
+	Name: "wirecheck",
+	Doc:  doc,
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || call.Ellipsis.IsValid() {
+				return true
+			}
+
+			switch wireopt.Func(pass.TypesInfo, call.Fun) {
+			case "Wire":
+				for _, arg := range call.Args {
+					checkProvider(pass, arg, nil)
+				}
+			case "WireWithOption":
+				if len(call.Args) != 0 {
+					checkProvider(pass, call.Args[0], call.Args[1:])
+				}
+			}
+
+			return true
+		})
+	}
+
+	return nil, nil
+}
+
+// checkProvider checks the provider with the expressions of options.
+func checkProvider(pass *analysis.Pass, expr ast.Expr, optExprs []ast.Expr) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok {
+		return
+	}
+	if tv.IsNil() {
+		pass.Reportf(expr.Pos(), "provider cannot be nil")
+		return
+	}
+
+	if isWireOption(pass.TypesInfo, expr) {
+		pass.Reportf(expr.Pos(), "provider cannot be wire option")
+		return
+	}
+
+	opts := make([]*wireopt.Option, 0, len(optExprs))
+	for _, e := range optExprs {
+		opt, err := wireopt.Eval(pass.TypesInfo, e)
+		// the option cannot be evaluated statically, give up checking the rest
+		if err != nil {
+			return
+		}
+		opts = append(opts, opt)
+	}
+
+	sig, ok := tv.Type.Underlying().(*types.Signature)
+	if !ok {
+		checkField(pass, expr, tv.Type, opts)
+		return
+	}
+
+	if sig.Results().Len() != 1 {
+		pass.Reportf(expr.Pos(), "provider should have exactly one out parameter, got %d",
+			sig.Results().Len())
+		return
+	}
+	inOpts, _, index, err := wireopt.Split(opts, sig.Params().Len())
+	if err != nil {
+		pass.Reportf(opts[index].Call.Pos(), "%v", err)
+		return
+	}
+
+	for i := 0; i < sig.Params().Len(); i++ {
+		param := sig.Params().At(i)
+		// the provider may be declared in other package, so report at the call site
+		if isClosure(param.Type()) {
+			pass.Reportf(expr.Pos(), "closure type %s of parameter %d cannot be wired, "+
+				"declare a named func type for it", param.Type(), i)
+		}
+		if i < len(inOpts) && inOpts[i].Default != nil {
+			checkDefault(pass, inOpts[i].Default, param.Type())
+		}
+	}
+	if out := sig.Results().At(0).Type(); isClosure(out) {
+		pass.Reportf(expr.Pos(), "closure type %s cannot be wired, declare a named "+
+			"func type for it", out)
+	}
+}
+
+// checkField checks the instantiated provider in the same way as container.
+func checkField(pass *analysis.Pass, expr ast.Expr, t types.Type,
+	opts []*wireopt.Option) {
+	if len(opts) > 1 || len(opts) == 1 && (!opts[0].Out || opts[0].Validate() != nil) {
+		pass.Reportf(expr.Pos(), "only one wire out option can be passed to wire "+
+			"instantiated provider")
+		return
+	}
+
+	var elem types.Type
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		elem = u.Elem()
+	case *types.Slice:
+		elem = u.Elem()
+	case *types.Chan, *types.Map:
+	default:
+		pass.Reportf(expr.Pos(), "only non nil pointer type, chan, map, slice can be "+
+			"passed to wire, got %s", t)
+		return
+	}
+
+	if len(opts) != 0 {
+		return
+	}
+	if elem != nil {
+		if _, ok := elem.Underlying().(*types.Struct); ok {
+			return
+		}
+	}
+	pass.Reportf(expr.Pos(), "primitive type %s should be provided with "+
+		"piper.WireWithOption and piper.OutName", t)
+}
+
+// checkDefault checks if the default value matches parameter.
+func checkDefault(pass *analysis.Pass, def ast.Expr, param types.Type) {
+	tv := pass.TypesInfo.Types[def]
+	if tv.Type == nil || tv.IsNil() {
+		return
+	}
+	if _, ok := tv.Type.Underlying().(*types.Signature); ok {
+		pass.Reportf(def.Pos(), "default value cannot be func")
+		return
+	}
+
+	if types.AssignableTo(tv.Type, param) {
+		return
+	}
+	if _, ok := param.Underlying().(*types.Interface); ok &&
+		types.ConvertibleTo(tv.Type, param) {
+		return
+	}
+	pass.Reportf(def.Pos(), "default value of type %s does not match parameter of "+
+		"type %s", tv.Type, param)
+}
+
+// isClosure checks if the type is an unnamed func, which cannot be parsed as field.
+func isClosure(t types.Type) bool {
+	switch u := t.(type) {
+	case *types.Pointer:
+		t = u.Elem()
+	case *types.Slice:
+		t = u.Elem()
+	}
+	_, ok := t.(*types.Signature)
+
+	return ok
+}
+
+// isWireOption checks if the type of expression is *piper.WireOption.
+func isWireOption(info *types.Info, expr ast.Expr) bool {
+	ptr, ok := info.TypeOf(expr).(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := ptr.Elem().(*types.Named)
+
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == wireopt.PiperPkg &&
+		named.Obj().Name() == "WireOption"
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2022 Vincent Cheung (coolingfall@gmail.com).
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wirecheck
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/go-piper/piper/internal/wireopt"
+)
+
+// stubImporter imports piper from the stub in testdata, and others from source.
+type stubImporter struct {
+	fset     *token.FileSet
+	fallback types.Importer
+}
+
+func (i *stubImporter) Import(path string) (*types.Package, error) {
+	if path != wireopt.PiperPkg {
+		return i.fallback.Import(path)
+	}
+
+	pkg, _, err := check(i.fset, filepath.Join("testdata", "src", wireopt.PiperPkg), path)
+	return pkg, err
+}
+
+// check parses and type checks the package in dir.
+func check(fset *token.FileSet, dir string, path string) (*types.Package, *analysis.Pass,
+	error) {
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := make([]*ast.File, 0)
+	for _, p := range pkgs {
+		for _, f := range p.Files {
+			files = append(files, f)
+		}
+	}
+
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	cfg := &types.Config{
+		Importer: &stubImporter{fset: fset, fallback: importer.ForCompiler(fset, "source", nil)},
+	}
+	pkg, err := cfg.Check(path, fset, files, info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pkg, &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      fset,
+		Files:     files,
+		Pkg:       pkg,
+		TypesInfo: info,
+	}, nil
+}
+
+func TestWireCheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "wire check test")
+}
+
+var _ = Describe("wire check", func() {
+	It("report at call sites", func() {
+		fset := token.NewFileSet()
+		_, pass, err := check(fset, filepath.Join("testdata", "src", "a"), "a")
+		Expect(err).NotTo(HaveOccurred())
+
+		got := make(map[int][]string)
+		pass.Report = func(d analysis.Diagnostic) {
+			line := fset.Position(d.Pos).Line
+			got[line] = append(got[line], d.Message)
+		}
+		_, err = Analyzer.Run(pass)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the expected messages are in the `// want` comments as analysistest does
+		want := make(map[int][]string)
+		wantRegexp := regexp.MustCompile("`([^`]*)`")
+		for _, f := range pass.Files {
+			for _, group := range f.Comments {
+				for _, c := range group.List {
+					if !regexp.MustCompile(`^// want `).MatchString(c.Text) {
+						continue
+					}
+					line := fset.Position(c.Pos()).Line
+					for _, m := range wantRegexp.FindAllStringSubmatch(c.Text, -1) {
+						want[line] = append(want[line], m[1])
+					}
+				}
+			}
+		}
+		Expect(want).NotTo(BeEmpty())
+
+		for line, patterns := range want {
+			Expect(got[line]).To(HaveLen(len(patterns)), "line %d: %v", line, got[line])
+			for i, pattern := range patterns {
+				Expect(got[line][i]).To(MatchRegexp(pattern), "line %d", line)
+			}
+		}
+		for line, messages := range got {
+			Expect(want).To(HaveKey(line), "unexpected %v at line %d", messages, line)
+		}
+	})
+})